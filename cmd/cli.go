@@ -3,7 +3,10 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -11,7 +14,6 @@ import (
 	"github.com/goodbins/go-jenkins-exporter/config"
 	"github.com/goodbins/go-jenkins-exporter/exporter"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -29,58 +31,120 @@ func RootCommand() *cobra.Command {
 		Long: `A simple jenkins exporter for prometheus, written in Go.
 
 Note: To setup jenkins credentials, use these environment variables:
-JENKINS_USERNAME, JENKINS_PASSWORD and/or JENKINS_TOKEN
-If they are not set, we assume no credentials.`,
+JENKINS_USERNAME, JENKINS_PASSWORD and/or JENKINS_TOKEN, or the
+credential flags below. If none is set, we assume no credentials.
+
+Every flag can also be set with a JENKINS_EXPORTER_* environment variable,
+e.g. JENKINS_EXPORTER_CRAWL_CONCURRENCY for --crawl-concurrency, or in the
+YAML or TOML config file given with --config, using the flag names as keys.
+Flags take precedence over the environment, which takes precedence over
+the config file.`,
 		Run:     run,
 		Version: config.CurrentVersion,
 	}
 
 	// Define and init flags
-	cobraCmd.Flags().BoolVarP(&config.Global.SSLOn, "ssl", "s", false, "Enable TLS (default false)")                                  // Optional
-	cobraCmd.Flags().StringVarP(&config.Global.JenkinsAPIHostPort, "jenkins", "j", "", "Jenkins API host:port pair")                  // Mendatory
-	cobraCmd.Flags().StringVarP(&config.Global.JenkinsAPIPath, "path", "a", "/api/json", "Jenkins API path")                          // Optional
-	cobraCmd.Flags().DurationVarP(&config.Global.JenkinsAPITimeout, "timeout", "t", 10*time.Second, "Jenkins API timeout in seconds") // Optional
-	cobraCmd.Flags().StringVarP(&config.Global.ExporterHostPort, "listen", "l", "localhost:5000", "Exporter host:port pair")          // Optional
-	cobraCmd.Flags().StringVarP(&config.Global.MetricsPath, "metrics", "m", "/metrics", "Path under which to expose metrics")         // Optional
-	cobraCmd.Flags().DurationVarP(&config.Global.MetricsUpdateRate, "rate", "r", 1*time.Second, "Set metrics update rate in seconds") // Optional
-	cobraCmd.Flags().BoolVarP(&config.Global.Verbose, "verbose", "v", false, "Enable verbosity. Overrides log flag")                                      // Optional
-	cobraCmd.Flags().StringVar(&config.Global.LogLevel, "log", "info", "Log level, one of: info, debug, warn, error, fatal")          // Optional
-	viper.BindEnv("username", "JENKINS_USERNAME")                                                                                     // Optional/Mendatory
-	viper.BindEnv("password", "JENKINS_PASSWORD")                                                                                     // Optional/Mendatory
-	viper.BindEnv("token", "JENKINS_TOKEN")                                                                                           // Optional/Mendatory
-	config.Global.JenkinsUsername = viper.GetString("username")
-	config.Global.JenkinsPassword = viper.GetString("password")
-	config.Global.JenkinsToken = viper.GetString("token")
-	config.Global.JenkinsWithCreds = true
+	cobraCmd.Flags().StringVarP(&config.Global.ConfigFile, "config", "c", "", "YAML or TOML config file")                                                                                         // Optional
+	cobraCmd.Flags().BoolVarP(&config.Global.SSLOn, "ssl", "s", false, "Enable TLS (default false)")                                                                                              // Optional
+	cobraCmd.Flags().StringVarP(&config.Global.JenkinsAPIHostPort, "jenkins", "j", "", "Jenkins API host:port pair")                                                                              // Mendatory
+	cobraCmd.Flags().StringVarP(&config.Global.JenkinsAPIPath, "path", "a", "/api/json", "Jenkins API path")                                                                                      // Optional
+	cobraCmd.Flags().StringVarP(&config.Global.JenkinsURL, "url", "u", "", "Jenkins base URL, with scheme and context path, e.g. https://ci.example.com/jenkins/. Overrides --jenkins and --ssl") // Optional
+	cobraCmd.Flags().StringVar(&config.Global.JenkinsPasswordFile, "password-file", "", "File holding the Jenkins password, read again when it changes")                                          // Optional
+	cobraCmd.Flags().StringVar(&config.Global.JenkinsTokenFile, "token-file", "", "File holding the Jenkins API token, read again when it changes")                                               // Optional
+	cobraCmd.Flags().BoolVar(&config.Global.JenkinsNetrc, "netrc", false, "Look up the Jenkins credentials by host in $NETRC or ~/.netrc (default false)")                                        // Optional
+	cobraCmd.Flags().StringVar(&config.Global.CredentialHelper, "credential-helper", "", "Command printing the Jenkins API token, with JENKINS_URL in its environment")                           // Optional
+	cobraCmd.Flags().StringVar(&config.Global.TLSCAFile, "tls-ca-file", "", "CA bundle to verify the Jenkins certificate with, instead of the system roots")                                      // Optional
+	cobraCmd.Flags().StringVar(&config.Global.TLSCertFile, "tls-cert-file", "", "Client certificate to present to Jenkins")                                                                       // Optional
+	cobraCmd.Flags().StringVar(&config.Global.TLSKeyFile, "tls-key-file", "", "Private key of the client certificate")                                                                            // Optional
+	cobraCmd.Flags().StringVar(&config.Global.TLSMinVersion, "tls-min-version", "1.2", "Minimum TLS version, one of: 1.0, 1.1, 1.2, 1.3")                                                         // Optional
+	cobraCmd.Flags().StringVar(&config.Global.TLSServerName, "tls-server-name", "", "Server name to verify the Jenkins certificate against, instead of the URL host")                             // Optional
+	cobraCmd.Flags().BoolVar(&config.Global.TLSInsecureSkipVerify, "tls-insecure-skip-verify", false, "Do not verify the Jenkins certificate. Insecure (default false)")                          // Optional
+	cobraCmd.Flags().StringVar(&config.Global.IncludeJobs, "include-jobs", "", "Only export the jobs whose full name matches this regular expression")                                            // Optional
+	cobraCmd.Flags().StringVar(&config.Global.ExcludeJobs, "exclude-jobs", "", "Do not export the jobs whose full name matches this regular expression")                                          // Optional
+	cobraCmd.Flags().DurationVarP(&config.Global.JenkinsAPITimeout, "timeout", "t", 10*time.Second, "Jenkins API timeout in seconds")                                                             // Optional
+	cobraCmd.Flags().IntVar(&config.Global.JenkinsAPIRetries, "retries", 3, "Number of retries of a failed Jenkins API request")                                                                  // Optional
+	cobraCmd.Flags().DurationVar(&config.Global.JenkinsAPIRetryBackoff, "retry-backoff", 500*time.Millisecond, "Initial delay between Jenkins API retries")                                       // Optional
+	cobraCmd.Flags().StringVarP(&config.Global.ExporterHostPort, "listen", "l", "localhost:5000", "Exporter host:port pair")                                                                      // Optional
+	cobraCmd.Flags().StringVarP(&config.Global.MetricsPath, "metrics", "m", "/metrics", "Path under which to expose metrics")                                                                     // Optional
+	cobraCmd.Flags().StringVar(&config.Global.WebConfigFile, "web-config-file", "", "Web config file enabling TLS and authentication on the exporter endpoints")                                  // Optional
+	cobraCmd.Flags().DurationVar(&config.Global.ReadyMaxAge, "ready-max-age", 0, "Max age of the last successful crawl for /-/ready, 0 for 3 times (update rate + crawl time)")                   // Optional
+	cobraCmd.Flags().DurationVarP(&config.Global.MetricsUpdateRate, "rate", "r", 30*time.Second, "Set metrics update rate in seconds")                                                            // Optional
+	cobraCmd.Flags().BoolVar(&config.Global.CrawlOnScrape, "crawl-on-scrape", false, "Crawl Jenkins on each scrape instead of at the update rate (default false)")                                // Optional
+	cobraCmd.Flags().DurationVar(&config.Global.SeriesTTL, "series-ttl", 0, "Keep the series of jobs missing from Jenkins until not seen for this long, 0 to remove them right away")             // Optional
+	cobraCmd.Flags().IntVar(&config.Global.CrawlConcurrency, "crawl-concurrency", 4, "Max concurrent Jenkins requests while crawling folders")                                                    // Optional
+	cobraCmd.Flags().IntVar(&config.Global.BuildHistory, "build-history", 0, "Number of recent builds per job to export history metrics from, 0 to disable")                                      // Optional
+	cobraCmd.Flags().BoolVar(&config.Global.PipelineStagesOn, "pipeline-stages", false, "Export pipeline stage metrics of the last builds (default false)")                                       // Optional
+	cobraCmd.Flags().BoolVar(&config.Global.TestReportsOn, "test-reports", false, "Export test suite metrics of the last completed builds (default false)")                                       // Optional
+	cobraCmd.Flags().IntVar(&config.Global.TestCasesTop, "test-cases-top", 0, "Number of slowest and failing test cases to export per job, 0 to disable")                                         // Optional
+	cobraCmd.Flags().BoolVar(&config.Global.PluginsOn, "plugins", false, "Export plugin metrics (default false)")                                                                                 // Optional
+	cobraCmd.Flags().DurationVar(&config.Global.PluginsUpdateRate, "plugins-rate", 1*time.Hour, "Set plugin metrics update rate")                                                                 // Optional
+	cobraCmd.Flags().BoolVarP(&config.Global.Verbose, "verbose", "v", false, "Enable verbosity. Overrides log flag")                                                                              // Optional
+	cobraCmd.Flags().StringVar(&config.Global.LogLevel, "log", "info", "Log level, one of: info, debug, warn, error, fatal")                                                                      // Optional
+	cobraCmd.Flags().MarkDeprecated("path", "it was never used, put the context path in --url instead")
 	return &cobraCmd
 }
 
 func run(cmd *cobra.Command, args []string) {
+	if err := loadConfig(cmd.Flags()); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 	ok := checkFlags()
 	if !ok {
 		fmt.Println("Use --help to get more info...")
 		os.Exit(1)
 	}
 	config.SetupLogging()
-	exporter.Serve()
+	exporter.Serve(reloader(cmd.Flags()))
 }
 
 func checkFlags() bool {
 	/* Check if mendatory flags are set */
-	// Check jenkins address
-	if config.Global.JenkinsAPIHostPort == "" {
-		fmt.Println("Jenkins host:port address is missing !")
-		return false
+	// Check jenkins address, unless the targets are listed in the config file
+	if len(config.Global.Targets) == 0 {
+		if config.Global.JenkinsURL == "" && config.Global.JenkinsAPIHostPort == "" {
+			fmt.Println("Jenkins URL or host:port address is missing !")
+			return false
+		}
+		config.Global.Targets = []config.Target{{}}
+	}
+	if config.Global.JenkinsURL != "" || config.Global.JenkinsAPIHostPort != "" {
+		baseURL, err := jenkinsBaseURL(config.Global.JenkinsURL)
+		if err != nil {
+			fmt.Println("Invalid Jenkins URL:", err)
+			return false
+		}
+		config.Global.JenkinsURL = baseURL
 	}
 
-	// Check if jenkins credentials are ok
-	if config.Global.JenkinsPassword == "" && config.Global.JenkinsToken == "" {
-		fmt.Println("Connecting to jenkins without credentials !")
-		config.Global.JenkinsWithCreds = false
+	// Check each target, with the settings they inherit
+	names := make(map[string]bool)
+	for i := range config.Global.Targets {
+		t := &config.Global.Targets[i]
+		t.Inherit(&config.Global)
+		if !checkTarget(t) {
+			return false
+		}
+		if names[t.Name] {
+			fmt.Println("Target names must be unique, " + t.Name + " is used twice !")
+			return false
+		}
+		names[t.Name] = true
+	}
+
+	// Check listen address
+	_, port, err := net.SplitHostPort(config.Global.ExporterHostPort)
+	if err != nil {
+		fmt.Println("Invalid listen address:", err)
+		return false
+	}
+	listenPort, err := strconv.Atoi(port)
+	if err != nil || listenPort < 0 || listenPort > 65535 {
+		fmt.Println("Invalid listen port: " + port)
+		return false
 	}
 
 	// If privileged port, check if user is root
-	listenPort, _ := strconv.Atoi(strings.Split(config.Global.ExporterHostPort, ":")[1])
 	if listenPort < 1024 {
 		// Check if caller is root
 		if os.Geteuid() != 0 {
@@ -89,6 +153,36 @@ func checkFlags() bool {
 		}
 	}
 
+	// Check retries
+	if config.Global.JenkinsAPIRetries < 0 {
+		fmt.Println("The number of retries can't be negative !")
+		return false
+	}
+
+	// Check readiness threshold
+	if config.Global.ReadyMaxAge < 0 {
+		fmt.Println("The readiness max age can't be negative !")
+		return false
+	}
+
+	// Check build history
+	if config.Global.BuildHistory < 0 {
+		fmt.Println("The build history size can't be negative !")
+		return false
+	}
+
+	// Check test cases top
+	if config.Global.TestCasesTop < 0 {
+		fmt.Println("The number of test cases can't be negative !")
+		return false
+	}
+
+	// Check crawl concurrency
+	if config.Global.CrawlConcurrency < 1 {
+		fmt.Println("Crawl concurrency must be at least 1 !")
+		return false
+	}
+
 	// Check log level
 	if _, ok := config.LogrusLevels[config.Global.LogLevel]; !ok {
 		fmt.Println("The log level you provided is not supported, using default - info")
@@ -97,3 +191,73 @@ func checkFlags() bool {
 
 	return true
 }
+
+// checkTarget checks the settings of a target and names it after the host
+// of its URL if it has no name
+func checkTarget(t *config.Target) bool {
+	if t.URL == "" {
+		fmt.Println("Jenkins URL of target " + t.Name + " is missing !")
+		return false
+	}
+	baseURL, err := jenkinsBaseURL(t.URL)
+	if err != nil {
+		fmt.Println("Invalid Jenkins URL:", err)
+		return false
+	}
+	t.URL = baseURL
+	if t.Name == "" {
+		u, _ := url.Parse(baseURL)
+		t.Name = u.Host
+	}
+
+	// Check if jenkins credentials are ok
+	if !t.HasCredentials() {
+		fmt.Println("Connecting to jenkins " + t.Name + " without credentials !")
+	}
+	for _, file := range []string{t.PasswordFile, t.TokenFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			fmt.Println("Credentials file not found:", err)
+			return false
+		}
+	}
+	if t.CredentialHelper != "" && len(strings.Fields(t.CredentialHelper)) == 0 {
+		fmt.Println("The credential helper command is empty !")
+		return false
+	}
+
+	// Check TLS options
+	if (t.TLSCertFile == "") != (t.TLSKeyFile == "") {
+		fmt.Println("The client certificate and its key must be set together !")
+		return false
+	}
+	if _, ok := config.TLSVersions[t.TLSMinVersion]; !ok {
+		fmt.Println("The minimum TLS version you provided is not supported !")
+		return false
+	}
+
+	// Check job filters
+	for _, filter := range []string{t.IncludeJobs, t.ExcludeJobs} {
+		if _, err := regexp.Compile(filter); err != nil {
+			fmt.Println("Invalid job filter:", err)
+			return false
+		}
+	}
+	return true
+}
+
+// jenkinsBaseURL validates a jenkins URL, built from --jenkins and --ssl
+// when empty, and returns it with a trailing slash so that API paths can be
+// appended to it
+func jenkinsBaseURL(raw string) (string, error) {
+	if raw == "" {
+		scheme := "http://"
+		if config.Global.SSLOn {
+			scheme = "https://"
+		}
+		raw = scheme + config.Global.JenkinsAPIHostPort
+	}
+	return config.BaseURL(raw)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+	"github.com/goodbins/go-jenkins-exporter/exporter"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Prefix of the environment variable bound to each flag, e.g.
+// JENKINS_EXPORTER_CRAWL_CONCURRENCY for --crawl-concurrency
+const envPrefix = "JENKINS_EXPORTER"
+
+// Flags that can't be set from the environment or the config file
+var commandLineOnly = map[string]bool{
+	"config":  true,
+	"help":    true,
+	"version": true,
+}
+
+// loadConfig completes the flags that were not set on the command line with
+// their environment variable, then with the config file if there is one.
+// Flags set nowhere keep their default value. The config file keys are the
+// flag names, plus username, password and token for the credentials, and
+// targets for the list of jenkins controllers.
+func loadConfig(flags *pflag.FlagSet) error {
+	v := viper.New()
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+
+	// The config file itself may come from the environment
+	v.BindEnv("config")
+	if !flags.Changed("config") {
+		config.Global.ConfigFile = v.GetString("config")
+	}
+	if config.Global.ConfigFile != "" {
+		v.SetConfigFile(config.Global.ConfigFile)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("could not read the config file: %v", err)
+		}
+	}
+
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || commandLineOnly[f.Name] {
+			return
+		}
+		v.BindEnv(f.Name)
+		if !v.IsSet(f.Name) {
+			return
+		}
+		if setErr := f.Value.Set(v.GetString(f.Name)); setErr != nil {
+			err = fmt.Errorf("invalid value for %s: %v", f.Name, setErr)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Credentials keep their historical environment variables
+	v.BindEnv("username", "JENKINS_USERNAME")
+	v.BindEnv("password", "JENKINS_PASSWORD")
+	v.BindEnv("token", "JENKINS_TOKEN")
+	config.Global.JenkinsUsername = v.GetString("username")
+	config.Global.JenkinsPassword = v.GetString("password")
+	config.Global.JenkinsToken = v.GetString("token")
+
+	// Targets can only be listed in the config file
+	config.Global.Targets = nil
+	if err := v.UnmarshalKey("targets", &config.Global.Targets); err != nil {
+		return fmt.Errorf("invalid targets: %v", err)
+	}
+	return nil
+}
+
+// reloader returns the loader of the exporter's reloads. It resets the flags
+// that were not set on the command line to their default before loading
+// the configuration again, so that settings removed from the environment
+// or the config file are dropped too.
+func reloader(flags *pflag.FlagSet) exporter.Loader {
+	return func() (config.Config, error) {
+		var err error
+		flags.VisitAll(func(f *pflag.Flag) {
+			if err != nil || f.Changed || commandLineOnly[f.Name] {
+				return
+			}
+			err = f.Value.Set(f.DefValue)
+		})
+		if err != nil {
+			return config.Config{}, err
+		}
+		if err := loadConfig(flags); err != nil {
+			return config.Config{}, err
+		}
+		// The reasons were printed by checkFlags
+		if !checkFlags() {
+			return config.Config{}, errors.New("invalid configuration")
+		}
+		return config.Global, nil
+	}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+)
+
+// setenv sets environment variables and returns a function unsetting them
+func setenv(vars map[string]string) func() {
+	for k, v := range vars {
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k := range vars {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	prev := config.Global
+	defer func() { config.Global = prev }()
+
+	dir, err := ioutil.TempDir("", "go-jenkins-exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.yml")
+	write := func(content string) {
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`
+url: http://file.example.com/
+listen: localhost:15000
+rate: 5s
+timeout: 3s
+crawl-concurrency: 2
+token: filetoken
+`)
+	unset := setenv(map[string]string{
+		"JENKINS_EXPORTER_CONFIG":  file,
+		"JENKINS_EXPORTER_TIMEOUT": "4s",
+		"JENKINS_TOKEN":            "envtoken",
+	})
+	defer unset()
+
+	flags := RootCommand().Flags()
+	if err := flags.Parse([]string{"--crawl-concurrency", "8"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(flags); err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"config file from the environment", config.Global.ConfigFile, file},
+		{"file over default", config.Global.MetricsUpdateRate, 5 * time.Second},
+		{"env over file", config.Global.JenkinsAPITimeout, 4 * time.Second},
+		{"flag over file", config.Global.CrawlConcurrency, 8},
+		{"default", config.Global.JenkinsAPIRetries, 3},
+		{"credentials env over file", config.Global.JenkinsToken, "envtoken"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	// Settings removed from the file and the environment go back to their
+	// default on reload, the command line ones stay
+	write(`
+url: http://file.example.com/
+listen: localhost:15000
+timeout: 3s
+`)
+	os.Unsetenv("JENKINS_EXPORTER_TIMEOUT")
+	cfg, err := reloader(flags)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks = []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"removed from the file", cfg.MetricsUpdateRate, 30 * time.Second},
+		{"removed from the environment", cfg.JenkinsAPITimeout, 3 * time.Second},
+		{"command line", cfg.CrawlConcurrency, 8},
+		{"credentials", cfg.JenkinsToken, "envtoken"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("reload, %s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestReloadRejectsInvalidListenAddress(t *testing.T) {
+	prev := config.Global
+	defer func() { config.Global = prev }()
+
+	flags := RootCommand().Flags()
+	for _, listen := range []string{"15001", "localhost:http", "localhost:99999"} {
+		unset := setenv(map[string]string{
+			"JENKINS_EXPORTER_URL":    "http://ci.example.com/",
+			"JENKINS_EXPORTER_LISTEN": listen,
+		})
+		if _, err := reloader(flags)(); err == nil {
+			t.Errorf("listen address %s accepted", listen)
+		}
+		unset()
+	}
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/tls"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -8,24 +10,69 @@ import (
 
 // Config Global configuration for the jenkins exporter
 type Config struct {
-	SSLOn              bool
-	JenkinsAPIHostPort string
-	JenkinsAPIPath     string
-	JenkinsAPITimeout  time.Duration
-	JenkinsUsername    string
-	JenkinsPassword    string
-	JenkinsToken       string
-	JenkinsWithCreds   bool
-	ExporterHostPort   string
-	MetricsPath        string
-	MetricsUpdateRate  time.Duration
-	Verbose            bool
-	LogLevel           string
+	ConfigFile             string
+	SSLOn                  bool
+	JenkinsAPIHostPort     string
+	JenkinsAPIPath         string
+	JenkinsURL             string
+	JenkinsAPITimeout      time.Duration
+	JenkinsAPIRetries      int
+	JenkinsAPIRetryBackoff time.Duration
+	JenkinsUsername        string
+	JenkinsPassword        string
+	JenkinsToken           string
+	JenkinsPasswordFile    string
+	JenkinsTokenFile       string
+	JenkinsNetrc           bool
+	CredentialHelper       string
+	TLSCAFile              string
+	TLSCertFile            string
+	TLSKeyFile             string
+	TLSMinVersion          string
+	TLSServerName          string
+	TLSInsecureSkipVerify  bool
+	IncludeJobs            string
+	ExcludeJobs            string
+	Targets                []Target
+	ExporterHostPort       string
+	MetricsPath            string
+	WebConfigFile          string
+	ReadyMaxAge            time.Duration
+	MetricsUpdateRate      time.Duration
+	CrawlOnScrape          bool
+	SeriesTTL              time.Duration
+	CrawlConcurrency       int
+	BuildHistory           int
+	PipelineStagesOn       bool
+	TestReportsOn          bool
+	TestCasesTop           int
+	PluginsOn              bool
+	PluginsUpdateRate      time.Duration
+	Verbose                bool
+	LogLevel               string
 }
 
-// Global The Global variable instance
+// Global The Global variable instance, the flags are parsed into it. The
+// exporter reads the configuration in use through Get instead, which is
+// swapped atomically when the configuration is reloaded.
 var Global Config
 
+var current atomic.Value // *Config
+
+// Get returns the configuration in use
+func Get() *Config {
+	cfg, _ := current.Load().(*Config)
+	if cfg == nil {
+		return &Global
+	}
+	return cfg
+}
+
+// Set makes a copy of cfg the configuration in use
+func Set(cfg Config) {
+	current.Store(&cfg)
+}
+
 // CurrentVersion version of the software
 const CurrentVersion string = "v0.2.1"
 
@@ -37,3 +84,11 @@ var LogrusLevels = map[string]logrus.Level{
 	"error": logrus.ErrorLevel,
 	"fatal": logrus.FatalLevel,
 }
+
+// TLS versions accepted as the minimum version spoken to jenkins
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
@@ -9,8 +9,8 @@ import (
 // SetupLogging setup the logging properties
 func SetupLogging() {
 	logrus.SetOutput(os.Stdout)
-	logrus.SetLevel(LogrusLevels[Global.LogLevel])
-	if Global.Verbose {
+	logrus.SetLevel(LogrusLevels[Get().LogLevel])
+	if Get().Verbose {
 		logrus.SetLevel(logrus.DebugLevel)
 	}
 	customFormatter := new(logrus.TextFormatter)
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Target is a jenkins controller to export the metrics of. Targets are
+// listed in the config file; without any, a single one is built from the
+// top level configuration.
+type Target struct {
+	Name                  string `mapstructure:"name"` // The controller label, the URL host by default
+	URL                   string `mapstructure:"url"`
+	Username              string `mapstructure:"username"`
+	Password              string `mapstructure:"password"`
+	Token                 string `mapstructure:"token"`
+	PasswordFile          string `mapstructure:"password-file"`
+	TokenFile             string `mapstructure:"token-file"`
+	Netrc                 bool   `mapstructure:"netrc"`
+	CredentialHelper      string `mapstructure:"credential-helper"`
+	TLSCAFile             string `mapstructure:"tls-ca-file"`
+	TLSCertFile           string `mapstructure:"tls-cert-file"`
+	TLSKeyFile            string `mapstructure:"tls-key-file"`
+	TLSMinVersion         string `mapstructure:"tls-min-version"`
+	TLSServerName         string `mapstructure:"tls-server-name"`
+	TLSInsecureSkipVerify bool   `mapstructure:"tls-insecure-skip-verify"`
+	IncludeJobs           string `mapstructure:"include-jobs"` // Regexp on the job full names
+	ExcludeJobs           string `mapstructure:"exclude-jobs"` // Regexp on the job full names
+}
+
+// Inherit takes the settings the target leaves empty from the top level
+// configuration. The sources of the password or token are taken as a whole,
+// and only if the target has none, so that a source of the top level never
+// overrides the one of the target. The user name is taken on its own.
+func (t *Target) Inherit(c *Config) {
+	inherit(&t.URL, c.JenkinsURL)
+	inherit(&t.Username, c.JenkinsUsername)
+	if !t.HasCredentials() {
+		t.Password, t.Token = c.JenkinsPassword, c.JenkinsToken
+		t.PasswordFile, t.TokenFile = c.JenkinsPasswordFile, c.JenkinsTokenFile
+		t.Netrc, t.CredentialHelper = c.JenkinsNetrc, c.CredentialHelper
+	}
+	inherit(&t.TLSCAFile, c.TLSCAFile)
+	inherit(&t.TLSCertFile, c.TLSCertFile)
+	inherit(&t.TLSKeyFile, c.TLSKeyFile)
+	inherit(&t.TLSMinVersion, c.TLSMinVersion)
+	inherit(&t.TLSServerName, c.TLSServerName)
+	inherit(&t.IncludeJobs, c.IncludeJobs)
+	inherit(&t.ExcludeJobs, c.ExcludeJobs)
+	t.TLSInsecureSkipVerify = t.TLSInsecureSkipVerify || c.TLSInsecureSkipVerify
+}
+
+// HasCredentials tells whether a source of the password or token is set, a
+// user name alone not being enough to authenticate
+func (t *Target) HasCredentials() bool {
+	return t.Password != "" || t.Token != "" ||
+		t.PasswordFile != "" || t.TokenFile != "" || t.Netrc || t.CredentialHelper != ""
+}
+
+// ClearCredentials removes every source of credentials
+func (t *Target) ClearCredentials() {
+	t.Username, t.Password, t.Token = "", "", ""
+	t.PasswordFile, t.TokenFile = "", ""
+	t.Netrc, t.CredentialHelper = false, ""
+}
+
+func inherit(setting *string, value string) {
+	if *setting == "" {
+		*setting = value
+	}
+}
+
+// BaseURL validates a jenkins URL and returns it with a trailing slash, so
+// that API paths can be appended to it
+func BaseURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%s: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%s: host is missing", raw)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("%s: set credentials through the environment, not in the URL", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%s: query and fragment are not allowed", raw)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u.String(), nil
+}
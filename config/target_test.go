@@ -0,0 +1,48 @@
+package config
+
+import "testing"
+
+func TestTargetInherit(t *testing.T) {
+	top := &Config{
+		JenkinsURL:       "https://ci.example.com/",
+		JenkinsUsername:  "exporter",
+		JenkinsPassword:  "toppassword",
+		JenkinsTokenFile: "/run/secrets/token",
+	}
+	tests := []struct {
+		name   string
+		target Target
+		want   Target
+	}{
+		{
+			name:   "everything inherited",
+			target: Target{},
+			want: Target{URL: "https://ci.example.com/", Username: "exporter",
+				Password: "toppassword", TokenFile: "/run/secrets/token"},
+		},
+		{
+			name:   "own token keeps the top level user name",
+			target: Target{Token: "token"},
+			want:   Target{URL: "https://ci.example.com/", Username: "exporter", Token: "token"},
+		},
+		{
+			name:   "own user name keeps the top level secrets",
+			target: Target{Username: "other"},
+			want: Target{URL: "https://ci.example.com/", Username: "other",
+				Password: "toppassword", TokenFile: "/run/secrets/token"},
+		},
+		{
+			name:   "own helper overrides every top level secret",
+			target: Target{URL: "https://release.example.com/", CredentialHelper: "get-token"},
+			want: Target{URL: "https://release.example.com/", Username: "exporter",
+				CredentialHelper: "get-token"},
+		},
+	}
+	for _, tt := range tests {
+		got := tt.target
+		got.Inherit(top)
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package exporter
+
+import (
+	"encoding/json"
+)
+
+// Time spent by a build in the queue, from the metrics plugin
+type timeInQueue struct {
+	QueuingDurationMillis int `json:"queuingDurationMillis"`
+	TotalDurationMillis   int `json:"totalDurationMillis"`
+}
+
+// Test counts of a build, from any test result action
+type testCounts struct {
+	SkipCount  int `json:"skipCount"`
+	FailCount  int `json:"failCount"`
+	TotalCount int `json:"totalCount"`
+}
+
+// PassCount returns the number of tests that neither failed nor were skipped
+func (t *testCounts) PassCount() int {
+	return t.TotalCount - t.FailCount - t.SkipCount
+}
+
+// buildActions gathers what we know how to read from the actions of a
+// build. A nil field means the build has no such action.
+type buildActions struct {
+	Queue *timeInQueue
+	Tests *testCounts
+}
+
+// actionDecoder reads one action of a build into its buildActions
+type actionDecoder func(raw json.RawMessage, a *buildActions) error
+
+// Action decoders, keyed by the _class of the action they read
+var actionDecoders = make(map[string]actionDecoder)
+
+// registerActionDecoder adds support for a new kind of build action
+func registerActionDecoder(class string, d actionDecoder) {
+	actionDecoders[class] = d
+}
+
+func init() {
+	registerActionDecoder("jenkins.metrics.impl.TimeInQueueAction", decodeTimeInQueue)
+	for _, class := range []string{
+		"hudson.tasks.junit.TestResultAction",
+		"hudson.tasks.test.AggregatedTestResultAction",
+		"hudson.maven.reporters.SurefireReport",
+		"hudson.maven.reporters.SurefireAggregatedReport",
+		"hudson.matrix.MatrixTestResult",
+	} {
+		registerActionDecoder(class, decodeTestCounts)
+	}
+}
+
+func decodeTimeInQueue(raw json.RawMessage, a *buildActions) error {
+	a.Queue = &timeInQueue{}
+	return json.Unmarshal(raw, a.Queue)
+}
+
+// decodeTestCounts adds up the counts when a build has several test actions
+func decodeTestCounts(raw json.RawMessage, a *buildActions) error {
+	var t testCounts
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return err
+	}
+	if a.Tests == nil {
+		a.Tests = &testCounts{}
+	}
+	a.Tests.SkipCount += t.SkipCount
+	a.Tests.FailCount += t.FailCount
+	a.Tests.TotalCount += t.TotalCount
+	return nil
+}
+
+// UnmarshalJSON picks the actions out of the list jenkins returns by their
+// _class, and skips the ones no decoder is registered for
+func (a *buildActions) UnmarshalJSON(data []byte) error {
+	var actions []json.RawMessage
+	if err := json.Unmarshal(data, &actions); err != nil {
+		return err
+	}
+	for _, raw := range actions {
+		var class struct {
+			Class string `json:"_class"`
+		}
+		if err := json.Unmarshal(raw, &class); err != nil {
+			return err
+		}
+		decode, ok := actionDecoders[class.Class]
+		if !ok {
+			continue
+		}
+		if err := decode(raw, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Action fields read by the decoders, for the tree query
+const actionsTree = "actions[_class,queuingDurationMillis,totalDurationMillis,skipCount,failCount,totalCount]"
@@ -0,0 +1,65 @@
+package exporter
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestBuildActionsUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want buildActions
+	}{
+		{"no actions", `[]`, buildActions{}},
+		{"empty and unknown actions", `[{}, {"_class":"hudson.model.CauseAction","causes":[]}]`, buildActions{}},
+		{
+			"time in queue",
+			`[{"_class":"jenkins.metrics.impl.TimeInQueueAction","queuingDurationMillis":1500,"totalDurationMillis":9000}]`,
+			buildActions{Queue: &timeInQueue{QueuingDurationMillis: 1500, TotalDurationMillis: 9000}},
+		},
+		{
+			"several actions",
+			`[{"_class":"hudson.model.CauseAction"},
+			  {"_class":"hudson.tasks.junit.TestResultAction","failCount":1,"skipCount":2,"totalCount":10},
+			  {"_class":"jenkins.metrics.impl.TimeInQueueAction","queuingDurationMillis":100,"totalDurationMillis":200}]`,
+			buildActions{
+				Queue: &timeInQueue{QueuingDurationMillis: 100, TotalDurationMillis: 200},
+				Tests: &testCounts{FailCount: 1, SkipCount: 2, TotalCount: 10},
+			},
+		},
+		{
+			"test counts summed",
+			`[{"_class":"hudson.tasks.junit.TestResultAction","failCount":1,"skipCount":0,"totalCount":4},
+			  {"_class":"hudson.maven.reporters.SurefireReport","failCount":2,"skipCount":1,"totalCount":6}]`,
+			buildActions{Tests: &testCounts{FailCount: 3, SkipCount: 1, TotalCount: 10}},
+		},
+	}
+	for _, tt := range tests {
+		var got buildActions
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if counts := (&testCounts{FailCount: 3, SkipCount: 1, TotalCount: 10}); counts.PassCount() != 6 {
+		t.Errorf("PassCount() = %d, want 6", counts.PassCount())
+	}
+}
+
+func TestBuildActionsUnmarshalJSONErrors(t *testing.T) {
+	for _, data := range []string{
+		`{}`,
+		`[{"_class":1}]`,
+		`[{"_class":"hudson.tasks.junit.TestResultAction","failCount":"one"}]`,
+	} {
+		var a buildActions
+		if err := json.Unmarshal([]byte(data), &a); err == nil {
+			t.Errorf("%s: no error", data)
+		}
+	}
+}
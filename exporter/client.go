@@ -0,0 +1,72 @@
+package exporter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+)
+
+// newClient builds the client of a target. It is shared by every request
+// made to the target, so connections are kept alive and reused from one
+// request and update cycle to the next.
+func newClient(cfg *config.Config, t config.Target) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(t)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Timeout:   cfg.JenkinsAPITimeout,
+		Transport: newTransport(cfg, tlsConfig),
+	}, nil
+}
+
+// newTransport returns a transport with the settings of the default one,
+// keeping enough idle connections for the concurrent crawl requests
+func newTransport(cfg *config.Config, tlsConfig *tls.Config) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   cfg.CrawlConcurrency,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// newTLSConfig loads the CA bundle and client certificate, if any
+func newTLSConfig(t config.Target) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         config.TLSVersions[t.TLSMinVersion],
+		ServerName:         t.TLSServerName,
+		InsecureSkipVerify: t.TLSInsecureSkipVerify,
+	}
+	if t.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(t.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the CA bundle: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in the CA bundle %s", t.TLSCAFile)
+		}
+	}
+	if t.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.TLSCertFile, t.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load the client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,147 @@
+package exporter
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Jenkins executor struct
+type executor struct {
+	Idle bool `json:"idle"`
+}
+
+// Jenkins computer (node) struct
+type computer struct {
+	Class              string `json:"_class"`
+	DisplayName        string `json:"displayName"`
+	Offline            bool   `json:"offline"`
+	TemporarilyOffline bool   `json:"temporarilyOffline"`
+	OfflineCause       *struct {
+		Class string `json:"_class"`
+	} `json:"offlineCause"`
+	OfflineCauseReason string     `json:"offlineCauseReason"`
+	NumExecutors       int        `json:"numExecutors"`
+	Executors          []executor `json:"executors"`
+	AssignedLabels     []struct {
+		Name string `json:"name"`
+	} `json:"assignedLabels"`
+}
+
+// Jenkins computer API response struct
+type computerResponse struct {
+	Computer []computer `json:"computer"`
+}
+
+// GetComputers returns the nodes attached to a jenkins target
+func GetComputers(t *target) ([]computer, error) {
+	logrus.Debug("Get nodes from jenkins ", t.name, "..")
+	var cResp computerResponse
+	err := getJSON(t, t.url+"computer/api/json?tree=computer[displayName,offline,temporarilyOffline,offlineCause[_class],offlineCauseReason,numExecutors,executors[idle],assignedLabels[name]]", &cResp)
+	if err != nil {
+		return nil, err
+	}
+	return cResp.Computer, nil
+}
+
+// busyExecutors returns the number of executors running a build
+func (c *computer) busyExecutors() int {
+	busy := 0
+	for _, e := range c.Executors {
+		if !e.Idle {
+			busy++
+		}
+	}
+	return busy
+}
+
+// offlineCause returns the short class name of the offline cause, like
+// UserCause or ChannelTermination
+func (c *computer) offlineCause() string {
+	if c.OfflineCause == nil || c.OfflineCause.Class == "" {
+		return "unknown"
+	}
+	cause := c.OfflineCause.Class
+	return cause[strings.LastIndexAny(cause, ".$")+1:]
+}
+
+var computerDescs map[string]*prometheus.Desc
+
+func init() {
+	computerDescs = make(map[string]*prometheus.Desc)
+	computerDescs["Online"] = newDesc(
+		"jenkins_node_online",
+		"Whether the jenkins node is online",
+		[]string{
+			"node",
+		},
+	)
+	computerDescs["TemporarilyOffline"] = newDesc(
+		"jenkins_node_temporarily_offline",
+		"Whether the jenkins node was marked temporarily offline",
+		[]string{
+			"node",
+		},
+	)
+	computerDescs["OfflineCause"] = newDesc(
+		"jenkins_node_offline_cause",
+		"Cause of the jenkins node being offline, set to 1 when offline",
+		[]string{
+			"node",
+			"cause",
+		},
+	)
+	computerDescs["Executors"] = newDesc(
+		"jenkins_node_executors",
+		"Number of executors of the jenkins node",
+		[]string{
+			"node",
+		},
+	)
+	computerDescs["BusyExecutors"] = newDesc(
+		"jenkins_node_executors_busy",
+		"Number of executors of the jenkins node running a build",
+		[]string{
+			"node",
+		},
+	)
+	computerDescs["IdleExecutors"] = newDesc(
+		"jenkins_node_executors_idle",
+		"Number of idle executors of the jenkins node",
+		[]string{
+			"node",
+		},
+	)
+	computerDescs["Label"] = newDesc(
+		"jenkins_node_label_info",
+		"Labels assigned to the jenkins node, always 1",
+		[]string{
+			"node",
+			"label",
+		},
+	)
+}
+
+func collectComputers(ch chan<- prometheus.Metric, snap *snapshot) {
+	for i := range snap.computers {
+		c := &snap.computers[i]
+		node := c.DisplayName
+		ch <- prometheus.MustNewConstMetric(computerDescs["Online"], prometheus.GaugeValue, b2F64(!c.Offline), node)
+		ch <- prometheus.MustNewConstMetric(computerDescs["TemporarilyOffline"], prometheus.GaugeValue, b2F64(c.TemporarilyOffline), node)
+		if c.Offline {
+			ch <- prometheus.MustNewConstMetric(computerDescs["OfflineCause"], prometheus.GaugeValue, 1, node, c.offlineCause())
+		}
+		busy := c.busyExecutors()
+		ch <- prometheus.MustNewConstMetric(computerDescs["Executors"], prometheus.GaugeValue, i2F64(c.NumExecutors), node)
+		ch <- prometheus.MustNewConstMetric(computerDescs["BusyExecutors"], prometheus.GaugeValue, i2F64(busy), node)
+		ch <- prometheus.MustNewConstMetric(computerDescs["IdleExecutors"], prometheus.GaugeValue, i2F64(len(c.Executors)-busy), node)
+		labels := make(map[string]bool, len(c.AssignedLabels))
+		for _, l := range c.AssignedLabels {
+			if !labels[l.Name] {
+				labels[l.Name] = true
+				ch <- prometheus.MustNewConstMetric(computerDescs["Label"], prometheus.GaugeValue, 1, node, l.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package exporter
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// controllerSet runs a crawler per target, so that a slow controller does
+// not hold back the others, and registers the collector of each of them
+type controllerSet struct {
+	mu         sync.Mutex
+	collectors map[string]*jenkinsCollector // Keyed by target name
+}
+
+var controllers = &controllerSet{collectors: make(map[string]*jenkinsCollector)}
+
+// apply starts crawling the new targets, hands the existing crawlers their
+// new target, keeping their metrics, and stops crawling the targets that
+// are gone, removing their metrics
+func (s *controllerSet) apply(targets []*target) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keep := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		keep[t.name] = true
+		if c, ok := s.collectors[t.name]; ok {
+			c.crawler.setTarget(t)
+			continue
+		}
+		c := newJenkinsCollector(newCrawler(t))
+		controllerRegisterer(t.name).MustRegister(c)
+		go c.crawler.run()
+		s.collectors[t.name] = c
+	}
+	for name, c := range s.collectors {
+		if keep[name] {
+			continue
+		}
+		controllerRegisterer(name).Unregister(c)
+		c.crawler.halt()
+		delete(s.collectors, name)
+	}
+}
+
+// crawlers returns the crawler of each target, by target name
+func (s *controllerSet) crawlers() map[string]*crawler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	crawlers := make(map[string]*crawler, len(s.collectors))
+	for name, c := range s.collectors {
+		crawlers[name] = c.crawler
+	}
+	return crawlers
+}
+
+// controllerRegisterer adds the controller label to the metrics of the
+// collectors it registers
+func controllerRegisterer(name string) prometheus.Registerer {
+	return prometheus.WrapRegistererWith(prometheus.Labels{"controller": name}, prometheus.DefaultRegisterer)
+}
@@ -0,0 +1,248 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+)
+
+// credentialProvider gives the credentials of a target. It is asked on
+// every request, so that rotated secrets are picked up without a reload.
+type credentialProvider interface {
+	// credentials returns the user name and the password or API token,
+	// an empty password meaning no credentials
+	credentials() (username, password string, err error)
+}
+
+// credentialInvalidator is implemented by the providers that cache the
+// credentials, to drop them once jenkins rejected them
+type credentialInvalidator interface {
+	invalidate()
+}
+
+// newCredentialProvider picks the source of the credentials of a target,
+// from the most to the least specific one
+func newCredentialProvider(cfg *config.Config, t config.Target) credentialProvider {
+	switch {
+	case t.CredentialHelper != "":
+		return &helperCredentials{
+			username: t.Username,
+			command:  strings.Fields(t.CredentialHelper),
+			url:      t.URL,
+			timeout:  cfg.JenkinsAPITimeout,
+		}
+	case t.TokenFile != "":
+		return &fileCredentials{username: t.Username, file: &watchedFile{path: t.TokenFile}}
+	case t.Token != "":
+		return staticCredentials{username: t.Username, password: t.Token}
+	case t.PasswordFile != "":
+		return &fileCredentials{username: t.Username, file: &watchedFile{path: t.PasswordFile}}
+	case t.Netrc:
+		return &netrcCredentials{host: hostname(t.URL), file: &watchedFile{path: netrcPath()}}
+	}
+	return staticCredentials{username: t.Username, password: t.Password}
+}
+
+// staticCredentials are set in the configuration
+type staticCredentials struct {
+	username string
+	password string
+}
+
+func (c staticCredentials) credentials() (string, string, error) {
+	return c.username, c.password, nil
+}
+
+// fileCredentials read the password or token from a file, mounted secrets
+// for instance
+type fileCredentials struct {
+	username string
+	file     *watchedFile
+}
+
+func (c *fileCredentials) credentials() (string, string, error) {
+	content, err := c.file.read()
+	if err != nil {
+		return "", "", err
+	}
+	return c.username, strings.TrimSpace(content), nil
+}
+
+// netrcCredentials look up the login and password of the target host in a
+// netrc file
+type netrcCredentials struct {
+	host string
+	file *watchedFile
+}
+
+func (c *netrcCredentials) credentials() (string, string, error) {
+	content, err := c.file.read()
+	if err != nil {
+		return "", "", err
+	}
+	login, password := netrcLookup(content, c.host)
+	return login, password, nil
+}
+
+// helperCredentials run a command that prints the API token. The token is
+// kept for a while, not to run the command on every request.
+type helperCredentials struct {
+	username string
+	command  []string
+	url      string // Given to the command as JENKINS_URL
+	timeout  time.Duration
+
+	mu        sync.Mutex
+	token     string
+	fetchedAt time.Time
+}
+
+// How long a token printed by a credential helper is used
+const credentialHelperTTL = time.Minute
+
+func (c *helperCredentials) credentials() (string, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Since(c.fetchedAt) < credentialHelperTTL {
+		return c.username, c.token, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.command[0], c.command[1:]...)
+	cmd.Env = append(os.Environ(), "JENKINS_URL="+c.url)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("credential helper failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	token := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	if token == "" {
+		return "", "", fmt.Errorf("credential helper printed no token")
+	}
+	c.token, c.fetchedAt = token, time.Now()
+	return c.username, c.token, nil
+}
+
+func (c *helperCredentials) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = ""
+}
+
+// watchedFile is a file read again only once it changed
+type watchedFile struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	content string
+}
+
+func (f *watchedFile) read() (string, error) {
+	// Stat follows symlinks, so secrets swapped through one are seen too
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.content, nil
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+	f.modTime, f.size, f.content = info.ModTime(), info.Size(), string(data)
+	return f.content, nil
+}
+
+// hostname returns the host of a URL, without the port
+func hostname(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// netrcPath returns the netrc file given by $NETRC, ~/.netrc by default
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".netrc")
+}
+
+// netrcLookup returns the login and password of host in a netrc file, or
+// the default ones if host has no entry
+func netrcLookup(content, host string) (string, string) {
+	var login, password string
+	matched, done := false, false
+	tokens := netrcTokens(content)
+	for i := 0; i < len(tokens) && !done; i++ {
+		value := ""
+		if i+1 < len(tokens) {
+			value = tokens[i+1]
+		}
+		switch tokens[i] {
+		case "machine":
+			// The entry of host is over
+			done = matched
+			matched = value == host
+			i++
+		case "default":
+			// The default entry comes last, and only applies to other hosts
+			done = matched
+			matched = true
+		case "login":
+			if matched {
+				login = value
+			}
+			i++
+		case "password":
+			if matched {
+				password = value
+			}
+			i++
+		case "account":
+			i++
+		}
+	}
+	return login, password
+}
+
+// netrcTokens splits a netrc file into tokens, leaving the macro
+// definitions out
+func netrcTokens(content string) []string {
+	var tokens []string
+	inMacro := false
+	for _, line := range strings.Split(content, "\n") {
+		// Macro definitions end with an empty line
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		for _, field := range strings.Fields(line) {
+			if field == "macdef" {
+				inMacro = true
+				break
+			}
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,48 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNetrcLookup(t *testing.T) {
+	const netrc = `machine ci.example.com
+  login exporter
+  password citoken
+
+macdef init
+machine ci.example.com login macro password frommacro
+
+machine release.example.com login releaser account ops password releasetoken
+default login anonymous password anonymoustoken
+machine late.example.com login late password latetoken
+`
+	tests := []struct {
+		name, content, host string
+		login, password     string
+	}{
+		{"first entry", netrc, "ci.example.com", "exporter", "citoken"},
+		{"account skipped", netrc, "release.example.com", "releaser", "releasetoken"},
+		{"missing host takes the default", netrc, "other.example.com", "anonymous", "anonymoustoken"},
+		{"entry after the default is ignored", netrc, "late.example.com", "anonymous", "anonymoustoken"},
+		{"missing host without default", "machine ci.example.com login exporter password citoken\n", "other.example.com", "", ""},
+		{"first matching entry wins", "machine ci login a password 1\nmachine ci login b password 2\n", "ci", "a", "1"},
+		{"default before a machine", "default login d password 0\nmachine ci login a password 1\n", "ci", "d", "0"},
+		{"empty file", "", "ci.example.com", "", ""},
+	}
+	for _, tt := range tests {
+		login, password := netrcLookup(tt.content, tt.host)
+		if login != tt.login || password != tt.password {
+			t.Errorf("%s: netrcLookup(%q) = %q, %q, want %q, %q", tt.name, tt.host, login, password, tt.login, tt.password)
+		}
+	}
+}
+
+func TestNetrcTokensSkipsMacros(t *testing.T) {
+	content := "machine ci login a macdef init\ncd /tmp\nput file\n\npassword 1\n"
+	got := netrcTokens(content)
+	want := []string{"machine", "ci", "login", "a", "password", "1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("netrcTokens() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package exporter
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrorKind classifies the errors met while talking to jenkins
+type ErrorKind int
+
+// Kinds of jenkins API errors
+const (
+	ErrTransport ErrorKind = iota // Connection refused, reset, DNS failure...
+	ErrTimeout                    // Jenkins did not answer in time
+	ErrAuth                       // HTTP 401 and 403
+	ErrNotFound                   // HTTP 404
+	ErrClient                     // Any other HTTP 4xx
+	ErrServer                     // HTTP 5xx
+	ErrDecode                     // The reply is not the JSON we expect
+)
+
+var errorKindNames = map[ErrorKind]string{
+	ErrTransport: "transport",
+	ErrTimeout:   "timeout",
+	ErrAuth:      "auth",
+	ErrNotFound:  "not found",
+	ErrClient:    "client",
+	ErrServer:    "server",
+	ErrDecode:    "decode",
+}
+
+func (k ErrorKind) String() string {
+	return errorKindNames[k]
+}
+
+// APIError is the error returned by every request made to jenkins
+type APIError struct {
+	Kind       ErrorKind
+	URL        string
+	StatusCode int   // Set when jenkins replied with an error code
+	Err        error // Underlying error, if any
+}
+
+func (e *APIError) Error() string {
+	// The tree query makes URLs unreadable in logs, leave it out
+	msg := fmt.Sprintf("jenkins %s error on %s", e.Kind, strings.SplitN(e.URL, "?", 2)[0])
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(": HTTP %d", e.StatusCode)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Temporary tells whether retrying the request may succeed
+func (e *APIError) Temporary() bool {
+	switch e.Kind {
+	case ErrTransport, ErrTimeout, ErrServer:
+		return true
+	case ErrClient:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// isErrorKind tells whether err is an APIError of the given kind
+func isErrorKind(err error, kind ErrorKind) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.Kind == kind
+}
+
+// newTransportError wraps an error returned by the http client
+func newTransportError(url string, err error) *APIError {
+	// Drop the url.Error wrapper, it repeats the URL
+	if uErr, ok := err.(*neturl.Error); ok {
+		err = uErr.Err
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return &APIError{Kind: ErrTimeout, URL: url, Err: err}
+	}
+	return &APIError{Kind: ErrTransport, URL: url, Err: err}
+}
+
+// newStatusError maps an HTTP error code to an APIError
+func newStatusError(url string, code int) *APIError {
+	e := &APIError{URL: url, StatusCode: code}
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		e.Kind = ErrAuth
+	case code == http.StatusNotFound:
+		e.Kind = ErrNotFound
+	case code >= 500:
+		e.Kind = ErrServer
+	default:
+		e.Kind = ErrClient
+	}
+	return e
+}
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+// Upper bound of the delay between two attempts
+const maxRetryBackoff = 30 * time.Second
+
+// withRetries calls fn until it succeeds, fails with a permanent error or
+// the configured number of retries is exhausted. Attempts are spaced with a
+// jittered exponential backoff.
+func withRetries(url string, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		apiErr, ok := err.(*APIError)
+		if !ok || !apiErr.Temporary() || attempt >= config.Get().JenkinsAPIRetries {
+			return err
+		}
+		wait := backoff(attempt)
+		logrus.Warn(err, ", retrying in ", wait)
+		time.Sleep(wait)
+	}
+}
+
+// backoff returns the delay before the next attempt: the base delay doubled
+// at each attempt, capped, and randomized over its upper half
+func backoff(attempt int) time.Duration {
+	d := config.Get().JenkinsAPIRetryBackoff
+	if d <= 0 {
+		return 0
+	}
+	for i := 0; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
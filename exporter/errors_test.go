@@ -0,0 +1,122 @@
+package exporter
+
+import (
+	"errors"
+	"net"
+	neturl "net/url"
+	"testing"
+	"time"
+)
+
+func TestNewStatusError(t *testing.T) {
+	tests := []struct {
+		code      int
+		kind      ErrorKind
+		temporary bool
+	}{
+		{401, ErrAuth, false},
+		{403, ErrAuth, false},
+		{404, ErrNotFound, false},
+		{400, ErrClient, false},
+		{429, ErrClient, true},
+		{500, ErrServer, true},
+		{503, ErrServer, true},
+	}
+	for _, tt := range tests {
+		err := newStatusError("http://ci/api/json", tt.code)
+		if err.Kind != tt.kind || err.Temporary() != tt.temporary {
+			t.Errorf("newStatusError(%d) = %s, temporary %v, want %s, temporary %v", tt.code, err.Kind, err.Temporary(), tt.kind, tt.temporary)
+		}
+	}
+}
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestNewTransportError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind ErrorKind
+	}{
+		{"refused", errors.New("connection refused"), ErrTransport},
+		{"timeout", timeoutError{}, ErrTimeout},
+		{"wrapped timeout", &neturl.Error{Op: "Get", URL: "http://ci/", Err: timeoutError{}}, ErrTimeout},
+	}
+	for _, tt := range tests {
+		err := newTransportError("http://ci/api/json", tt.err)
+		if err.Kind != tt.kind || !err.Temporary() {
+			t.Errorf("%s: kind %s, temporary %v, want %s, temporary", tt.name, err.Kind, err.Temporary(), tt.kind)
+		}
+	}
+	// The URL is not repeated
+	if err := newTransportError("http://ci/", &neturl.Error{Op: "Get", URL: "http://ci/", Err: timeoutError{}}); err.Err != (timeoutError{}) {
+		t.Errorf("url.Error kept: %v", err.Err)
+	}
+}
+
+func TestWithRetries(t *testing.T) {
+	cfg := testConfig()
+	cfg.JenkinsAPIRetries = 2
+	defer useConfig(cfg)()
+	tests := []struct {
+		name     string
+		errs     []error // Returned by the successive attempts, nil once exhausted
+		attempts int
+		failed   bool
+	}{
+		{"success", nil, 1, false},
+		{"recovers", []error{newStatusError("u", 503)}, 2, false},
+		{"retries exhausted", []error{newStatusError("u", 502), newStatusError("u", 503), newStatusError("u", 500)}, 3, true},
+		{"permanent", []error{newStatusError("u", 404)}, 1, true},
+		{"auth", []error{newStatusError("u", 401)}, 1, true},
+		{"not an API error", []error{errors.New("boom")}, 1, true},
+	}
+	for _, tt := range tests {
+		attempts := 0
+		err := withRetries("u", func() error {
+			attempts++
+			if attempts <= len(tt.errs) {
+				return tt.errs[attempts-1]
+			}
+			return nil
+		})
+		if attempts != tt.attempts || (err != nil) != tt.failed {
+			t.Errorf("%s: %d attempts, error %v, want %d attempts, failed %v", tt.name, attempts, err, tt.attempts, tt.failed)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := testConfig()
+	cfg.JenkinsAPIRetryBackoff = time.Second
+	defer useConfig(cfg)()
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{3, 8 * time.Second},
+		{10, maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if d := backoff(tt.attempt); d < tt.max/2 || d > tt.max {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, d, tt.max/2, tt.max)
+			}
+		}
+	}
+
+	cfg.JenkinsAPIRetryBackoff = 0
+	defer useConfig(cfg)()
+	if d := backoff(3); d != 0 {
+		t.Errorf("backoff(3) = %s without a base delay, want 0", d)
+	}
+}
@@ -0,0 +1,96 @@
+package exporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+	"github.com/sirupsen/logrus"
+)
+
+// readiness is the reply of /-/ready
+type readiness struct {
+	Ready       bool                  `json:"ready"`
+	Controllers []controllerReadiness `json:"controllers"`
+}
+
+// controllerReadiness tells whether the metrics of a controller are fresh
+type controllerReadiness struct {
+	Name        string     `json:"name"`
+	Ready       bool       `json:"ready"`
+	Reason      string     `json:"reason,omitempty"` // Why it is not ready
+	LastCrawl   *time.Time `json:"last_crawl,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Healthy replies that the exporter is alive. It does not depend on jenkins,
+// which a restart of the exporter would not bring back.
+func Healthy(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain")
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte("Healthy"))
+}
+
+// Ready replies whether the exporter serves fresh metrics: it is ready
+// once a controller was crawled successfully no longer ago than the
+// readiness max age, so that a controller down does not take the metrics
+// of the others out of rotation. The reply is the state of the crawl of
+// each controller, as JSON, with a 503 status when none is ready.
+func Ready(rw http.ResponseWriter, req *http.Request) {
+	cfg := config.Get()
+	now := time.Now()
+	status := readiness{Controllers: []controllerReadiness{}}
+	for name, c := range controllers.crawlers() {
+		cr := controllerReadiness{Name: name}
+		snap := c.snapshot()
+		if snap == nil || snap.lastSuccessAt.IsZero() {
+			cr.Reason = "no successful crawl yet"
+		} else if maxAge := readyMaxAge(cfg, snap); now.Sub(snap.lastSuccessAt) > maxAge {
+			cr.Reason = "last successful crawl is older than " + maxAge.String()
+		} else {
+			cr.Ready = true
+		}
+		if snap != nil {
+			cr.LastCrawl = timeOrNil(snap.finishedAt)
+			cr.LastSuccess = timeOrNil(snap.lastSuccessAt)
+			cr.LastError, cr.LastErrorAt = snap.lastError, timeOrNil(snap.lastErrorAt)
+		}
+		status.Ready = status.Ready || cr.Ready
+		status.Controllers = append(status.Controllers, cr)
+	}
+	sort.Slice(status.Controllers, func(i, j int) bool {
+		return status.Controllers[i].Name < status.Controllers[j].Name
+	})
+
+	code := http.StatusOK
+	if !status.Ready {
+		logrus.Debug("Not ready, no controller has fresh metrics")
+		code = http.StatusServiceUnavailable
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	json.NewEncoder(rw).Encode(status)
+}
+
+// readyMaxAge returns how old the last successful crawl of a controller can
+// be for it to be ready. By default, that is 3 times the update rate plus
+// the length of its last successful update cycle, as the next snapshot is
+// published that long after the previous one.
+func readyMaxAge(cfg *config.Config, snap *snapshot) time.Duration {
+	if cfg.ReadyMaxAge > 0 {
+		return cfg.ReadyMaxAge
+	}
+	return 3 * (cfg.MetricsUpdateRate + snap.successCycle)
+}
+
+// timeOrNil leaves unset times out of the JSON replies
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,39 @@
+package exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+)
+
+// useConfig makes cfg the configuration in use, and returns a function
+// restoring the previous one
+func useConfig(cfg config.Config) func() {
+	prev := *config.Get()
+	config.Set(cfg)
+	return func() { config.Set(prev) }
+}
+
+// testConfig is a minimal configuration, without retries
+func testConfig() config.Config {
+	return config.Config{
+		JenkinsAPITimeout: time.Second,
+		CrawlConcurrency:  1,
+		TLSMinVersion:     "1.2",
+	}
+}
+
+// fakeJenkins serves handler and returns a target pointing to it
+func fakeJenkins(t *testing.T, handler http.HandlerFunc) (*target, func()) {
+	srv := httptest.NewServer(handler)
+	cfg := config.Get()
+	tg, err := newTarget(cfg, config.Target{Name: "test", URL: srv.URL + "/", TLSMinVersion: "1.2"})
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return tg, srv.Close
+}
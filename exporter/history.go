@@ -0,0 +1,115 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// From 10 seconds to about 6 hours
+var buildDurationBuckets = prometheus.ExponentialBuckets(10, 2, 12)
+
+var buildDurationDesc = newDesc(
+	"jenkins_job_build_duration_seconds",
+	"Duration of the completed builds of the job",
+	[]string{
+		"jobname",
+	},
+)
+
+var buildSuccessRatioDesc = newDesc(
+	"jenkins_job_build_success_ratio",
+	"Ratio of successful builds among the completed builds of the history window",
+	[]string{
+		"jobname",
+	},
+)
+
+// buildHistogram is the histogram of the build durations of a job. Like
+// snapshots, it is copied rather than modified once published.
+type buildHistogram struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64 // Cumulative counts, keyed by upper bound
+}
+
+// observe returns a copy of the histogram with v added to it
+func (h *buildHistogram) observe(v float64) *buildHistogram {
+	next := &buildHistogram{count: 1, sum: v, buckets: make(map[float64]uint64, len(buildDurationBuckets))}
+	if h != nil {
+		next.count += h.count
+		next.sum += h.sum
+	}
+	for _, b := range buildDurationBuckets {
+		if h != nil {
+			next.buckets[b] = h.buckets[b]
+		}
+		if v <= b {
+			next.buckets[b]++
+		}
+	}
+	return next
+}
+
+// completed tells whether the build is over. Builds have no result until
+// then, but may get one before, e.g. when marked unstable by a test step,
+// with no duration yet.
+func (b *jStatus) completed() bool {
+	return b.Result != "" && !b.Building
+}
+
+// observeBuilds adds the completed builds of the jobs history that were not
+// seen by a previous crawl to the duration histograms. Jobs that are gone
+// are dropped, and only the builds still in the history window are kept as
+// observed.
+func observeBuilds(prev *snapshot, jobs []job) (map[string]*buildHistogram, map[string]map[int]bool) {
+	histograms := make(map[string]*buildHistogram, len(jobs))
+	observed := make(map[string]map[int]bool, len(jobs))
+	for _, j := range jobs {
+		h := prev.histograms[j.FullName]
+		seen := make(map[int]bool, len(j.Builds))
+		for _, b := range j.Builds {
+			if !b.completed() {
+				continue
+			}
+			seen[b.Number] = true
+			if !prev.observedBuilds[j.FullName][b.Number] {
+				h = h.observe(i2F64(b.Duration) / 1000)
+			}
+		}
+		if h != nil {
+			histograms[j.FullName] = h
+		}
+		observed[j.FullName] = seen
+	}
+	return histograms, observed
+}
+
+// successRatio returns the ratio of successful builds among the completed
+// builds of the job history, and false if none is completed
+func successRatio(j *job) (float64, bool) {
+	completed, successful := 0, 0
+	for _, b := range j.Builds {
+		if !b.completed() {
+			continue
+		}
+		completed++
+		if b.Result == "SUCCESS" {
+			successful++
+		}
+	}
+	if completed == 0 {
+		return 0, false
+	}
+	return i2F64(successful) / i2F64(completed), true
+}
+
+func collectHistory(ch chan<- prometheus.Metric, snap *snapshot) {
+	for i := range snap.jobs {
+		j := &snap.jobs[i]
+		if h, ok := snap.histograms[j.FullName]; ok {
+			ch <- prometheus.MustNewConstHistogram(buildDurationDesc, h.count, h.sum, h.buckets, j.FullName)
+		}
+		if ratio, ok := successRatio(j); ok {
+			ch <- prometheus.MustNewConstMetric(buildSuccessRatioDesc, prometheus.GaugeValue, ratio, j.FullName)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package exporter
+
+import "testing"
+
+func TestObserveBuildsSkipsRunningBuilds(t *testing.T) {
+	j := job{FullName: "app", Builds: []jStatus{
+		{Number: 3, Result: "UNSTABLE", Building: true},
+		{Number: 2, Result: "SUCCESS", Duration: 20000},
+		{Number: 1, Result: "FAILURE", Duration: 40000},
+	}}
+	histograms, observed := observeBuilds(&snapshot{}, []job{j})
+	if h := histograms["app"]; h == nil || h.count != 2 || h.sum != 60 {
+		t.Fatalf("histogram = %+v, want 2 builds for 60s", h)
+	}
+
+	// Observed once it is over, with its final duration
+	j.Builds[0] = jStatus{Number: 3, Result: "UNSTABLE", Duration: 30000}
+	prev := &snapshot{histograms: histograms, observedBuilds: observed}
+	histograms, _ = observeBuilds(prev, []job{j})
+	if h := histograms["app"]; h.count != 3 || h.sum != 90 {
+		t.Errorf("histogram = %+v, want 3 builds for 90s", h)
+	}
+}
+
+func TestSuccessRatio(t *testing.T) {
+	tests := []struct {
+		name   string
+		builds []jStatus
+		ratio  float64
+		ok     bool
+	}{
+		{"no builds", nil, 0, false},
+		{"only running", []jStatus{{Building: true}, {Result: "FAILURE", Building: true}}, 0, false},
+		{"running skipped", []jStatus{{Result: "UNSTABLE", Building: true}, {Result: "SUCCESS"}, {Result: "FAILURE"}}, 0.5, true},
+		{"all successful", []jStatus{{Result: "SUCCESS"}, {Result: "SUCCESS"}}, 1, true},
+	}
+	for _, tt := range tests {
+		ratio, ok := successRatio(&job{Builds: tt.builds})
+		if ratio != tt.ratio || ok != tt.ok {
+			t.Errorf("%s: successRatio() = %v, %v, want %v, %v", tt.name, ratio, ok, tt.ratio, tt.ok)
+		}
+	}
+}
@@ -3,48 +3,99 @@ package exporter
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/goodbins/go-jenkins-exporter/config"
 	"github.com/sirupsen/logrus"
 )
 
-// Jenkins job actions struct
-type jActions struct {
-	Class                 string `json:"_class"`
-	QueuingDurationMillis int    `json:"queuingDurationMillis"`
-	TotalDurationMillis   int    `json:"totalDurationMillis"`
-	SkipCount             int    `json:"skipCount"`
-	FailCount             int    `json:"failCount"`
-	TotalCount            int    `json:"totalCount"`
-	PassCount             int    `json:"passCount"`
-}
-
 // Jenkins job statuses struct
 type jStatus struct {
-	Class     string `json:"_class"`
-	Actions   []jActions
-	Duration  int `json:"duration"`
-	Number    int `json:"number"`
-	Timestamp int `json:"timestamp"`
+	Class             string       `json:"_class"`
+	Actions           buildActions `json:"actions"`
+	Duration          int          `json:"duration"`
+	EstimatedDuration int          `json:"estimatedDuration"`
+	Number            int          `json:"number"`
+	Timestamp         int          `json:"timestamp"`
+	Result            string       `json:"result"`
+	Building          bool         `json:"building"`
+}
+
+// Jenkins job health report struct
+type healthReport struct {
+	Score       int    `json:"score"`
+	Description string `json:"description"`
 }
 
-// Jenkins job struct
+// Jenkins job struct. Jenkins replies null for the builds a job does not
+// have, like the last failed build of a job that never failed.
 type job struct {
-	Class                 string  `json:"_class"`
-	FullName              string  `json:"fullName"`
-	URL                   string  `json:"url"`
-	LastBuild             jStatus `json:"lastBuild"`
-	LastCompletedBuild    jStatus `json:"lastCompletedBuild"`
-	LastFailedBuild       jStatus `json:"lastFailedBuild"`
-	LastStableBuild       jStatus `json:"lastStableBuild"`
-	LastSuccessfulBuild   jStatus `json:"lastSuccessfulBuild"`
-	LastUnstableBuild     jStatus `json:"lastUnstableBuild"`
-	LastUnsuccessfulBuild jStatus `json:"lastUnsuccessfulBuild"`
+	Class                 string         `json:"_class"`
+	FullName              string         `json:"fullName"`
+	URL                   string         `json:"url"`
+	Color                 string         `json:"color"`
+	Buildable             bool           `json:"buildable"`
+	Disabled              bool           `json:"disabled"`
+	InQueue               bool           `json:"inQueue"`
+	HealthReport          []healthReport `json:"healthReport"`
+	LastBuild             *jStatus       `json:"lastBuild"`
+	LastCompletedBuild    *jStatus       `json:"lastCompletedBuild"`
+	LastFailedBuild       *jStatus       `json:"lastFailedBuild"`
+	LastStableBuild       *jStatus       `json:"lastStableBuild"`
+	LastSuccessfulBuild   *jStatus       `json:"lastSuccessfulBuild"`
+	LastUnstableBuild     *jStatus       `json:"lastUnstableBuild"`
+	LastUnsuccessfulBuild *jStatus       `json:"lastUnsuccessfulBuild"`
+	Builds                []jStatus      `json:"builds"`
+}
+
+// status returns the build of the job matching one of jobStatuses, nil when
+// the job has no such build
+func (j *job) status(name string) *jStatus {
+	switch name {
+	case "lastBuild":
+		return j.LastBuild
+	case "lastCompletedBuild":
+		return j.LastCompletedBuild
+	case "lastFailedBuild":
+		return j.LastFailedBuild
+	case "lastStableBuild":
+		return j.LastStableBuild
+	case "lastSuccessfulBuild":
+		return j.LastSuccessfulBuild
+	case "lastUnstableBuild":
+		return j.LastUnstableBuild
+	case "lastUnsuccessfulBuild":
+		return j.LastUnsuccessfulBuild
+	}
+	return nil
+}
+
+// isDisabled tells whether the job is disabled. Not every job type exports
+// the disabled field, but all of them show it in their ball color.
+func (j *job) isDisabled() bool {
+	return j.Disabled || strings.HasPrefix(j.Color, "disabled")
+}
+
+// healthScore returns the weather score of the job, that is the worst of
+// its health reports, and false if it has none
+func (j *job) healthScore() (int, bool) {
+	if len(j.HealthReport) == 0 {
+		return 0, false
+	}
+	score := j.HealthReport[0].Score
+	for _, h := range j.HealthReport[1:] {
+		if h.Score < score {
+			score = h.Score
+		}
+	}
+	return score, true
 }
 
 // Jenkins API response struct
@@ -58,38 +109,184 @@ var jenkinsFolderClasses = []string{
 	"jenkins.branch.OrganizationFolder",
 	"org.jenkinsci.plugins.workflow.multibranch.WorkflowMultiBranchProject"}
 
-var jobsList []job                 // List of discovered jobs
-var jobFloderLinks []string        // List of job folders
-var jobFolderVisitedLinks []string // List of visited/explored folders
+// crawl holds the state of a single walk through the jenkins job tree.
+// A fresh crawl is created on every call to GetData so nothing is carried
+// over from one update cycle to the next. Folders are fed to a work queue
+// drained by a bounded number of workers.
+type crawl struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	target  *target
+	root    string          // URL the crawl starts from
+	jobs    map[string]job  // Discovered jobs, keyed by URL
+	folders []string        // List of discovered job folders
+	visited map[string]bool // Set of queued/explored folders
+	todo    []string        // Folders waiting to be explored
+	active  int             // Folders being explored right now
+	err     error           // First error met during the crawl
+}
+
+func newCrawl(t *target) *crawl {
+	c := &crawl{
+		target:  t,
+		jobs:    make(map[string]job),
+		visited: make(map[string]bool),
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
 
-func GetData() *[]job {
-	logrus.Debug("Get data from jenkins..")
-	walkAndGetJobs(getJenkinsApiUrl())
-	logrus.Debug("Data retrieved successfully")
-	return &jobsList
+// GetData crawls a jenkins target and returns the jobs found during this
+// crawl that pass its filters, sorted by their full name, and the number of
+// folders explored. When an error is returned, the jobs are the ones that
+// could be retrieved before or despite the error.
+func GetData(t *target) (*[]job, int, error) {
+	logrus.Debug("Get data from jenkins ", t.name, "..")
+	c := newCrawl(t)
+	c.run(t.url, config.Get().CrawlConcurrency)
+	jobs := c.sortedJobs()
+	if c.err != nil {
+		return &jobs, len(c.folders), c.err
+	}
+	logrus.Debug("Data retrieved successfully: ", len(jobs), " jobs in ", len(c.folders), " folders")
+	return &jobs, len(c.folders), nil
 }
 
-// First url is the API's
-func walkAndGetJobs(url string) {
+// run explores the job tree from the API's url using at most workers
+// concurrent requests, and returns once every folder has been explored
+func (c *crawl) run(url string, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	c.root = url
+	c.enqueue([]string{url})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.worker()
+		}()
+	}
+	wg.Wait()
+}
+
+func (c *crawl) worker() {
+	for {
+		url, ok := c.next()
+		if !ok {
+			return
+		}
+		c.walkAndGetJobs(url)
+	}
+}
+
+// next blocks until a folder is available, and returns false once the queue
+// is empty and no other worker may add to it anymore
+func (c *crawl) next() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.todo) == 0 && c.active > 0 {
+		c.cond.Wait()
+	}
+	if len(c.todo) == 0 {
+		return "", false
+	}
+	url := c.todo[0]
+	c.todo = c.todo[1:]
+	c.active++
+	return url, true
+}
+
+func (c *crawl) walkAndGetJobs(url string) {
 	logrus.Debug("Walking ", url)
-	jobs := requestJson(url + "api/json" + createQuery())
-	jobFolderVisitedLinks = append(jobFolderVisitedLinks, url)
-	updateJobsAndFolders(jobs, &jobsList, &jobFloderLinks)
-	for _, fL := range jobFloderLinks {
-		if !isVisited(&fL) {
-			walkAndGetJobs(fL)
+	var folders []string
+	jobs, err := requestJson(c.target, url+"api/json"+createQuery())
+	switch {
+	case err == nil:
+		folders = c.updateJobsAndFolders(jobs)
+	case isErrorKind(err, ErrNotFound) && url != c.root:
+		// The folder was removed since its parent was listed
+		logrus.Debug("Folder ", url, " is gone, skipping it")
+	default:
+		c.fail(err)
+	}
+	c.finish(folders)
+}
+
+// fail records the first error met during the crawl
+func (c *crawl) fail(err error) {
+	logrus.Error(err)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// enqueue adds the folders that were not seen yet to the work queue
+func (c *crawl) enqueue(folders []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queue(folders)
+	c.cond.Broadcast()
+}
+
+// finish marks a folder as explored and queues the folders found in it at
+// once, so that no worker sees an empty queue and no active worker in
+// between, and quits while there is work left
+func (c *crawl) finish(folders []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+	c.queue(folders)
+	c.cond.Broadcast()
+}
+
+// queue must be called with c.mu held
+func (c *crawl) queue(folders []string) {
+	for _, fL := range folders {
+		if c.isVisited(fL) {
+			continue
 		}
+		c.visited[fL] = true
+		c.todo = append(c.todo, fL)
 	}
 }
 
-func updateJobsAndFolders(reply, jL *[]job, jF *[]string) {
+// updateJobsAndFolders sorts a jenkins reply into jobs and folders and
+// returns the folders found in it
+func (c *crawl) updateJobsAndFolders(reply *[]job) []string {
+	var folders []string
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	for _, j := range *reply {
+		j.URL = c.target.rebaseURL(j.URL)
 		if isJobsFolder(&j.Class) {
-			*jF = append(*jF, j.URL)
+			folders = append(folders, j.URL)
+			continue
+		}
+		if !c.target.exports(j.FullName) {
 			continue
 		}
-		*jL = append(*jL, j)
+		c.jobs[j.URL] = j
 	}
+	c.folders = append(c.folders, folders...)
+	return folders
+}
+
+func (c *crawl) sortedJobs() []job {
+	jobs := make([]job, 0, len(c.jobs))
+	for _, j := range c.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		if jobs[i].FullName != jobs[k].FullName {
+			return jobs[i].FullName < jobs[k].FullName
+		}
+		return jobs[i].URL < jobs[k].URL
+	})
+	return jobs
 }
 
 func isJobsFolder(class *string) bool {
@@ -101,72 +298,99 @@ func isJobsFolder(class *string) bool {
 	return false
 }
 
-func isVisited(link *string) bool {
-	for _, j := range jobFolderVisitedLinks {
-		if *link == j {
-			return true
-		}
+// isVisited must be called with c.mu held
+func (c *crawl) isVisited(link string) bool {
+	return c.visited[link]
+}
+
+// fetchPerJob calls fetch for each job, with no more than the crawl
+// concurrency running at once, and returns when all calls are done
+func fetchPerJob(jobs []*job, fetch func(j *job)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, config.Get().CrawlConcurrency)
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			fetch(j)
+		}(j)
 	}
-	return false
+	wg.Wait()
 }
 
-func requestJson(url string) *[]job {
-	// Init a map whose keys are strings and whose values are themselves
-	// stored as empty interface values
+func requestJson(t *target, url string) (*[]job, error) {
 	var jResp JenkinsResponse
-	resp := request(url)
-	defer resp.Body.Close()
-	// Decode to json the jenkins reply
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
+	if err := getJSON(t, url, &jResp); err != nil {
+		return nil, err
 	}
-	err = json.Unmarshal(body, &jResp)
-	if err != nil {
-		logrus.Error("An error has occured while decoding JSON")
-		panic(err)
-	}
-	return &jResp.Jobs
+	return &jResp.Jobs, nil
 }
 
-func request(apiurl string) *http.Response {
-	// Init an http client
-	httpClient := &http.Client{Timeout: config.Global.JenkinsAPITimeout * time.Second}
-	// Init a http request, set basic auth and Do the request
-	req, err := http.NewRequest("GET", apiurl, nil)
-	// Test if credentials are used
-	if config.Global.JenkinsWithCreds {
-		if config.Global.JenkinsPassword != "" {
-			req.SetBasicAuth(config.Global.JenkinsUsername, config.Global.JenkinsPassword)
+// getJSON requests url from the target and decodes the jenkins reply into
+// v, retrying on transient failures
+func getJSON(t *target, url string, v interface{}) error {
+	return withRetries(url, func() error {
+		resp, err := request(t, url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return newTransportError(url, err)
 		}
-		if config.Global.JenkinsToken != "" {
-			req.SetBasicAuth(config.Global.JenkinsUsername, config.Global.JenkinsToken)
+		// Decode to json the jenkins reply
+		if err = json.Unmarshal(body, v); err != nil {
+			t.metrics.decodeErrors.WithLabelValues().Inc()
+			return &APIError{Kind: ErrDecode, URL: url, Err: err}
 		}
+		return nil
+	})
+}
+
+// request makes a single GET request to jenkins. Error replies are turned
+// into an APIError and their body is discarded.
+func request(t *target, apiurl string) (*http.Response, error) {
+	// Init a http request, set basic auth and Do the request
+	req, err := http.NewRequest("GET", apiurl, nil)
+	if err != nil {
+		return nil, &APIError{Kind: ErrClient, URL: apiurl, Err: err}
+	}
+	// Test if credentials are used, they may have changed since the last
+	// request
+	username, password, err := t.creds.credentials()
+	if err != nil {
+		return nil, &APIError{Kind: ErrAuth, URL: apiurl, Err: fmt.Errorf("could not get the credentials: %v", err)}
+	}
+	if password != "" {
+		req.SetBasicAuth(username, password)
 	}
 	// Make the request
-	resp, err := httpClient.Do(req)
+	start := time.Now()
+	resp, err := t.client.Do(req)
+	t.metrics.duration.WithLabelValues().Observe(time.Since(start).Seconds())
+	if err != nil {
+		apiErr := newTransportError(apiurl, err)
+		t.metrics.requests.WithLabelValues(apiErr.Kind.String()).Inc()
+		return nil, apiErr
+	}
+	t.metrics.requests.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
 	// Control the response code
 	logrus.Debug("Request HTTP response code ", resp.StatusCode)
 	if resp.StatusCode >= 400 {
-		logrus.Error("An error occured while requesting Jenkins. HTTP response code ", resp.StatusCode)
-		os.Exit(1)
-	}
-	// Panic if an error occurs
-	if err != nil {
-		logrus.Error("An error has occured when getting: ", apiurl)
-		panic(err)
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		apiErr := newStatusError(apiurl, resp.StatusCode)
+		// Rejected credentials are fetched again by the next request
+		if inv, ok := t.creds.(credentialInvalidator); ok && apiErr.Kind == ErrAuth {
+			inv.invalidate()
+		}
+		return nil, apiErr
 	}
 	// Return the Jenskins response
-	return resp
-}
-
-func getJenkinsApiUrl() string {
-	var apiurl string = "http://"
-	if config.Global.SSLOn {
-		apiurl = "https://"
-	}
-	apiurl += config.Global.JenkinsAPIHostPort + "/"
-	return apiurl
+	return resp, nil
 }
 
 var jobStatuses = []string{
@@ -179,6 +403,21 @@ var jobStatuses = []string{
 	"lastUnsuccessfulBuild",
 }
 
+// Statuses whose result changes from one build to another
+var resultStatuses = []string{
+	"lastBuild",
+	"lastCompletedBuild",
+}
+
+// Results a jenkins build may end with
+var buildResults = []string{
+	"SUCCESS",
+	"FAILURE",
+	"UNSTABLE",
+	"ABORTED",
+	"NOT_BUILT",
+}
+
 func createQuery() string {
 
 	var jobStatusProperties string = `[
@@ -186,20 +425,20 @@ func createQuery() string {
 		number,
 		timestamp,
 		duration,
-		actions[
-			queuingDurationMillis,
-			totalDurationMillis,
-			skipCount,
-			failCount,
-			totalCount,
-			passCount]]`
+		estimatedDuration,
+		result,
+		building,
+		` + actionsTree + `]`
 
 	var query string
 	for _, s := range jobStatuses {
 		query += "," + s + jobStatusProperties
 	}
+	if config.Get().BuildHistory > 0 {
+		query += fmt.Sprintf(",builds[number,duration,result,timestamp,building]{0,%d}", config.Get().BuildHistory)
+	}
 	return strings.ReplaceAll(strings.ReplaceAll(
-		fmt.Sprintf("?tree=jobs[fullName,url%s]", query),
+		fmt.Sprintf("?tree=jobs[fullName,url,color,buildable,disabled,inQueue,healthReport[score,description]%s]", query),
 		"\n", ""),
 		"\t", "")
 }
@@ -0,0 +1,82 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCrawlEnqueue(t *testing.T) {
+	c := newCrawl(nil)
+	c.enqueue([]string{"a/", "b/"})
+	c.enqueue([]string{"b/", "c/", "a/"})
+	if want := []string{"a/", "b/", "c/"}; !reflect.DeepEqual(c.todo, want) {
+		t.Errorf("todo = %v, want %v", c.todo, want)
+	}
+}
+
+func TestCrawlNext(t *testing.T) {
+	c := newCrawl(nil)
+	c.enqueue([]string{"a/", "b/"})
+	for _, want := range []string{"a/", "b/"} {
+		url, ok := c.next()
+		if !ok || url != want {
+			t.Fatalf("next() = %q, %v, want %q, true", url, ok, want)
+		}
+	}
+	if c.active != 2 {
+		t.Errorf("active = %d, want 2", c.active)
+	}
+	c.finish(nil)
+	c.finish(nil)
+	if url, ok := c.next(); ok {
+		t.Errorf("next() = %q, true on a finished crawl", url)
+	}
+}
+
+func TestCrawlNextWaitsForActiveWorkers(t *testing.T) {
+	c := newCrawl(nil)
+	c.enqueue([]string{"root/"})
+	c.next()
+
+	type result struct {
+		url string
+		ok  bool
+	}
+	got := make(chan result)
+	go func() {
+		url, ok := c.next()
+		got <- result{url, ok}
+	}()
+	select {
+	case r := <-got:
+		t.Fatalf("next() = %q, %v while a folder is being explored", r.url, r.ok)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The folder found by the active worker goes to the waiting one
+	c.finish([]string{"root/job/f/", "root/"})
+	select {
+	case r := <-got:
+		if !r.ok || r.url != "root/job/f/" {
+			t.Fatalf("next() = %q, %v, want %q, true", r.url, r.ok, "root/job/f/")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("next() still blocked after a folder was queued")
+	}
+
+	// Nothing left once the last active worker found no folder
+	go func() {
+		url, ok := c.next()
+		got <- result{url, ok}
+	}()
+	c.finish(nil)
+	select {
+	case r := <-got:
+		if r.ok {
+			t.Fatalf("next() = %q, true on a finished crawl", r.url)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("next() still blocked after the crawl finished")
+	}
+}
@@ -0,0 +1,151 @@
+package exporter
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Class of the pipeline jobs, the only ones the stage view describes
+const pipelineJobClass = "org.jenkinsci.plugins.workflow.job.WorkflowJob"
+
+// Pipeline stage view stage struct
+type stage struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	Status              string `json:"status"`
+	DurationMillis      int    `json:"durationMillis"`
+	PauseDurationMillis int    `json:"pauseDurationMillis"`
+}
+
+// Pipeline stage view run struct, as returned by wfapi/describe
+type pipelineRun struct {
+	ID     string  `json:"id"`
+	Status string  `json:"status"`
+	Stages []stage `json:"stages"`
+}
+
+// Statuses a pipeline stage may be in
+var stageStatuses = []string{
+	"SUCCESS",
+	"FAILED",
+	"UNSTABLE",
+	"ABORTED",
+	"IN_PROGRESS",
+	"PAUSED_PENDING_INPUT",
+	"NOT_EXECUTED",
+}
+
+// cachedRun is the stage view of a job's last build. Finished builds don't
+// change anymore, so they are only requested once.
+type cachedRun struct {
+	jobName  string
+	number   int
+	complete bool // Whether the build was over when its stage view was requested
+	run      *pipelineRun
+}
+
+// complete tells whether the run is over, its stages won't change anymore
+func (r *pipelineRun) complete() bool {
+	return r.Status != "IN_PROGRESS" && r.Status != "PAUSED_PENDING_INPUT"
+}
+
+// GetPipelineRun returns the stage view of the last build of a pipeline job
+func GetPipelineRun(t *target, j *job) (*pipelineRun, error) {
+	var run pipelineRun
+	if err := getJSON(t, j.URL+"lastBuild/wfapi/describe", &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+var stageDurationDesc = newDesc(
+	"jenkins_pipeline_stage_duration_seconds",
+	"Duration of the pipeline stage in the last build of the job",
+	[]string{
+		"jobname",
+		"stage",
+	},
+)
+
+var stagePauseDurationDesc = newDesc(
+	"jenkins_pipeline_stage_pause_duration_seconds",
+	"Time the pipeline stage spent paused in the last build of the job",
+	[]string{
+		"jobname",
+		"stage",
+	},
+)
+
+var stageStatusDesc = newDesc(
+	"jenkins_pipeline_stage_status",
+	"Status of the pipeline stage in the last build of the job, 1 for the current status and 0 for the others",
+	[]string{
+		"jobname",
+		"stage",
+		"status",
+	},
+)
+
+// refreshPipelineRuns returns the stage views of the last build of the
+// pipeline jobs, keyed by job URL. Only the ones whose last build changed or
+// was still running when they were requested are requested again, the
+// others are taken from prev.
+func refreshPipelineRuns(t *target, jobs []job, prev map[string]cachedRun) map[string]cachedRun {
+	var mu sync.Mutex
+	var stale []*job
+	runs := make(map[string]cachedRun)
+	for i := range jobs {
+		j := &jobs[i]
+		if j.Class != pipelineJobClass || j.LastBuild == nil {
+			continue
+		}
+		cached, ok := prev[j.URL]
+		if ok && cached.number == j.LastBuild.Number && cached.complete {
+			runs[j.URL] = cached
+			continue
+		}
+		stale = append(stale, j)
+	}
+	fetchPerJob(stale, func(j *job) {
+		run, err := GetPipelineRun(t, j)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err == nil:
+			runs[j.URL] = cachedRun{
+				jobName:  j.FullName,
+				number:   j.LastBuild.Number,
+				complete: !j.LastBuild.Building && run.complete(),
+				run:      run,
+			}
+		case isErrorKind(err, ErrNotFound):
+			// The pipeline stage view plugin is not installed
+			logrus.Debug("No stage view for ", j.FullName)
+		default:
+			logrus.Warn("Could not get the stages of ", j.FullName, ": ", err)
+			if cached, ok := prev[j.URL]; ok {
+				runs[j.URL] = cached
+			}
+		}
+	})
+	return runs
+}
+
+func collectPipelines(ch chan<- prometheus.Metric, snap *snapshot) {
+	for _, cached := range snap.pipelineRuns {
+		// Stage names are not unique in every pipeline, keep the last one
+		stages := make(map[string]stage, len(cached.run.Stages))
+		for _, s := range cached.run.Stages {
+			stages[s.Name] = s
+		}
+		for _, s := range stages {
+			ch <- prometheus.MustNewConstMetric(stageDurationDesc, prometheus.GaugeValue, i2F64(s.DurationMillis)/1000, cached.jobName, s.Name)
+			ch <- prometheus.MustNewConstMetric(stagePauseDurationDesc, prometheus.GaugeValue, i2F64(s.PauseDurationMillis)/1000, cached.jobName, s.Name)
+			for _, st := range stageStatuses {
+				ch <- prometheus.MustNewConstMetric(stageStatusDesc, prometheus.GaugeValue, b2F64(s.Status == st), cached.jobName, s.Name, st)
+			}
+		}
+	}
+}
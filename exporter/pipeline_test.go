@@ -0,0 +1,40 @@
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRefreshPipelineRunsRequestsRunningBuildsAgain(t *testing.T) {
+	defer useConfig(testConfig())()
+	var status atomic.Value
+	status.Store("IN_PROGRESS")
+	var requests int32
+	tg, stop := fakeJenkins(t, func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprintf(rw, `{"id":"5","status":%q,"stages":[{"name":"build","status":%[1]q}]}`, status.Load())
+	})
+	defer stop()
+
+	j := job{Class: pipelineJobClass, FullName: "app", URL: tg.url + "job/app/", LastBuild: &jStatus{Number: 5, Building: true}}
+	runs := refreshPipelineRuns(tg, []job{j}, nil)
+
+	// The build finished since the stage view was requested
+	status.Store("SUCCESS")
+	j.LastBuild = &jStatus{Number: 5, Result: "SUCCESS"}
+	runs = refreshPipelineRuns(tg, []job{j}, runs)
+	if got := runs[j.URL].run.Status; got != "SUCCESS" {
+		t.Errorf("status = %s after the build finished, want SUCCESS", got)
+	}
+
+	// Complete runs are not requested again
+	runs = refreshPipelineRuns(tg, []job{j}, runs)
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("%d stage view requests, want 2", got)
+	}
+	if !runs[j.URL].complete {
+		t.Error("finished run not cached as complete")
+	}
+}
@@ -0,0 +1,72 @@
+package exporter
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Jenkins plugin struct
+type plugin struct {
+	ShortName string `json:"shortName"`
+	Version   string `json:"version"`
+	Enabled   bool   `json:"enabled"`
+	Active    bool   `json:"active"`
+	HasUpdate bool   `json:"hasUpdate"`
+	// Security warnings published for the installed version
+	ActiveWarnings []struct {
+		ID string `json:"id"`
+	} `json:"activeWarnings"`
+}
+
+// Jenkins plugin manager API response struct
+type pluginResponse struct {
+	Plugins []plugin `json:"plugins"`
+}
+
+// GetPlugins returns the plugins installed on a jenkins target
+func GetPlugins(t *target) ([]plugin, error) {
+	logrus.Debug("Get plugins from jenkins ", t.name, "..")
+	var pResp pluginResponse
+	if err := getJSON(t, t.url+"pluginManager/api/json?depth=1", &pResp); err != nil {
+		return nil, err
+	}
+	return pResp.Plugins, nil
+}
+
+var pluginInfoDesc = newDesc(
+	"jenkins_plugin_info",
+	"Plugins installed on jenkins, always 1",
+	[]string{
+		"name",
+		"version",
+		"enabled",
+		"active",
+	},
+)
+
+var pluginUpdateDesc = newDesc(
+	"jenkins_plugin_update_available",
+	"Whether a newer version of the plugin is available",
+	[]string{
+		"name",
+	},
+)
+
+var pluginWarningDesc = newDesc(
+	"jenkins_plugin_security_warning",
+	"Whether a security warning applies to the installed version of the plugin",
+	[]string{
+		"name",
+	},
+)
+
+func collectPlugins(ch chan<- prometheus.Metric, snap *snapshot) {
+	for _, p := range snap.plugins {
+		ch <- prometheus.MustNewConstMetric(pluginInfoDesc, prometheus.GaugeValue, 1,
+			p.ShortName, p.Version, strconv.FormatBool(p.Enabled), strconv.FormatBool(p.Active))
+		ch <- prometheus.MustNewConstMetric(pluginUpdateDesc, prometheus.GaugeValue, b2F64(p.HasUpdate), p.ShortName)
+		ch <- prometheus.MustNewConstMetric(pluginWarningDesc, prometheus.GaugeValue, b2F64(len(p.ActiveWarnings) > 0), p.ShortName)
+	}
+}
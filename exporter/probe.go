@@ -0,0 +1,90 @@
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// Probe crawls the jenkins given by the target query parameter and replies
+// with its metrics, from a registry made for this scrape only. The target
+// is either the name of a configured target or a jenkins URL. A URL takes
+// the settings of the target named by the module query parameter if there
+// is one, and the top level settings otherwise. Credentials are only kept
+// for a URL on the scheme and host of the module target, so that they are
+// never sent to an arbitrary URL.
+func Probe(rw http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	t, err := probeTarget(query.Get("target"), query.Get("module"))
+	if err != nil {
+		logrus.Debug("Bad probe request: ", err)
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer t.closeIdleConnections()
+	logrus.Debug("Probing ", t.name)
+
+	registry := prometheus.NewRegistry()
+	reg := prometheus.WrapRegistererWith(prometheus.Labels{"controller": t.name}, registry)
+	t.metrics = probeAPIMetrics(reg)
+	// Crawl before gathering, for the request metrics to count the crawl
+	c := newCrawler(t)
+	c.update()
+	reg.MustRegister(&jenkinsCollector{crawler: c, probe: true})
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(rw, req)
+}
+
+// probeTarget builds the target of a probe request
+func probeTarget(name, module string) (*target, error) {
+	cfg := config.Get()
+	if name == "" {
+		return nil, fmt.Errorf("target parameter is missing")
+	}
+	if tc, ok := configuredTarget(cfg, name); ok && module == "" {
+		return newTarget(cfg, tc)
+	}
+
+	baseURL, err := config.BaseURL(name)
+	if err != nil {
+		return nil, fmt.Errorf("target is neither a configured target nor a valid URL: %v", err)
+	}
+	u, _ := neturl.Parse(baseURL)
+	var tc config.Target
+	if module != "" {
+		var ok bool
+		if tc, ok = configuredTarget(cfg, module); !ok {
+			return nil, fmt.Errorf("unknown module %s", module)
+		}
+		if !sameOrigin(u, tc.URL) {
+			logrus.Debug("Probing ", baseURL, " without the credentials of module ", module, ", not on its host")
+			tc.ClearCredentials()
+		}
+	} else {
+		tc.Inherit(cfg)
+		tc.ClearCredentials()
+	}
+	tc.Name, tc.URL = u.Host, baseURL
+	return newTarget(cfg, tc)
+}
+
+// sameOrigin tells whether u has the scheme and host of the URL of a target
+func sameOrigin(u *neturl.URL, targetURL string) bool {
+	t, err := neturl.Parse(targetURL)
+	return err == nil && u.Scheme == t.Scheme && strings.EqualFold(u.Host, t.Host)
+}
+
+// configuredTarget returns the configured target of the given name
+func configuredTarget(cfg *config.Config, name string) (config.Target, bool) {
+	for _, tc := range cfg.Targets {
+		if tc.Name == name {
+			return tc, true
+		}
+	}
+	return config.Target{}, false
+}
@@ -0,0 +1,75 @@
+package exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strings"
+	"testing"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestProbeTargetCredentials(t *testing.T) {
+	cfg := testConfig()
+	cfg.Targets = []config.Target{{
+		Name:     "ci",
+		URL:      "https://ci.example.com/jenkins/",
+		Username: "exporter",
+		Token:    "citoken",
+	}}
+	defer useConfig(cfg)()
+	tests := []struct {
+		name, target, module string
+		password             string
+	}{
+		{"configured target", "ci", "", "citoken"},
+		{"module host", "https://ci.example.com/other/", "ci", "citoken"},
+		{"other host", "https://evil.example.com/", "ci", ""},
+		{"other port", "https://ci.example.com:8443/jenkins/", "ci", ""},
+		{"other scheme", "http://ci.example.com/jenkins/", "ci", ""},
+		{"no module", "https://ci.example.com/jenkins/", "", ""},
+	}
+	for _, tt := range tests {
+		tg, err := probeTarget(tt.target, tt.module)
+		if err != nil {
+			t.Errorf("%s: probeTarget(%q, %q): %v", tt.name, tt.target, tt.module, err)
+			continue
+		}
+		_, password, err := tg.creds.credentials()
+		if err != nil || password != tt.password {
+			t.Errorf("%s: probeTarget(%q, %q) password = %q, %v, want %q", tt.name, tt.target, tt.module, password, err, tt.password)
+		}
+	}
+}
+
+func TestProbeKeepsItsMetricsToItself(t *testing.T) {
+	defer useConfig(testConfig())()
+	tg, stop := fakeJenkins(t, func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("{}"))
+	})
+	defer stop()
+	host := strings.TrimSuffix(strings.TrimPrefix(tg.url, "http://"), "/")
+
+	rw := httptest.NewRecorder()
+	Probe(rw, httptest.NewRequest("GET", "/probe?target="+neturl.QueryEscape(tg.url), nil))
+	want := `jenkins_exporter_api_requests_total{code="200",controller="` + host + `"}`
+	if body := rw.Body.String(); !strings.Contains(body, want) {
+		t.Errorf("probe reply misses %s:\n%s", want, body)
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "controller" && l.GetValue() == host {
+					t.Errorf("probe added %s{controller=%q} to the default registry", f.GetName(), host)
+				}
+			}
+		}
+	}
+}
@@ -3,217 +3,392 @@ package exporter
 import (
 	"regexp"
 	"strings"
-	"time"
 
 	"github.com/goodbins/go-jenkins-exporter/config"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
-	"github.com/sirupsen/logrus"
 )
 
-var prometheusMetrics map[string]*prometheus.GaugeVec
+var jobDescs map[string]*prometheus.Desc
 
 func init() {
-	prometheusMetrics = make(map[string]*prometheus.GaugeVec)
+	jobDescs = make(map[string]*prometheus.Desc)
 	// Loop through statuses to create per status metrics
 	for _, s := range jobStatuses {
 		// Number
-		prometheusMetrics[s+"Number"] = promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: "jenkins_job_" + toSnakeCase(s) + "_number",
-				Help: "Jenkins build number for " + s,
-			},
+		jobDescs[s+"Number"] = newDesc(
+			"jenkins_job_"+toSnakeCase(s)+"_number",
+			"Jenkins build number for "+s,
 			[]string{
 				"jobname",
 			},
 		)
 		// Duration
-		prometheusMetrics[s+"Duration"] = promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: "jenkins_job_" + toSnakeCase(s) + "_duration_seconds",
-				Help: "Jenkins build duration in seconds for " + s,
-			},
+		jobDescs[s+"Duration"] = newDesc(
+			"jenkins_job_"+toSnakeCase(s)+"_duration_seconds",
+			"Jenkins build duration in seconds for "+s,
 			[]string{
 				"jobname",
 			},
 		)
 		// Timestamp
-		prometheusMetrics[s+"Timestamp"] = promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: "jenkins_job_" + toSnakeCase(s) + "_timestamp_seconds",
-				Help: "Jenkins build timestamp in unixtime for " + s,
+		jobDescs[s+"Timestamp"] = newDesc(
+			"jenkins_job_"+toSnakeCase(s)+"_timestamp_seconds",
+			"Jenkins build timestamp in unixtime for "+s,
+			[]string{
+				"jobname",
 			},
+		)
+		// Estimated duration
+		jobDescs[s+"EstimatedDuration"] = newDesc(
+			"jenkins_job_"+toSnakeCase(s)+"_estimated_duration_seconds",
+			"Jenkins build estimated duration in seconds for "+s,
 			[]string{
 				"jobname",
 			},
 		)
 		// Queuing duration
-		prometheusMetrics[s+"QueuingDuration"] = promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: "jenkins_job_" + toSnakeCase(s) + "_queuing_duration_seconds",
-				Help: "Jenkins build queuing duration in seconds for " + s,
-			},
+		jobDescs[s+"QueuingDuration"] = newDesc(
+			"jenkins_job_"+toSnakeCase(s)+"_queuing_duration_seconds",
+			"Jenkins build queuing duration in seconds for "+s,
 			[]string{
 				"jobname",
 			},
 		)
 		// Total duration
-		prometheusMetrics[s+"TotalDuration"] = promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: "jenkins_job_" + toSnakeCase(s) + "_total_duration_seconds",
-				Help: "Jenkins build total duration in seconds for " + s,
-			},
+		jobDescs[s+"TotalDuration"] = newDesc(
+			"jenkins_job_"+toSnakeCase(s)+"_total_duration_seconds",
+			"Jenkins build total duration in seconds for "+s,
 			[]string{
 				"jobname",
 			},
 		)
 		// Skip counts
-		prometheusMetrics[s+"SkipCounts"] = promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: "jenkins_job_" + toSnakeCase(s) + "_skip_count",
-				Help: "Jenkins build skip counts for " + s,
-			},
+		jobDescs[s+"SkipCounts"] = newDesc(
+			"jenkins_job_"+toSnakeCase(s)+"_skip_count",
+			"Jenkins build skip counts for "+s,
 			[]string{
 				"jobname",
 			},
 		)
 		// Fail counts
-		prometheusMetrics[s+"FailCounts"] = promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: "jenkins_job_" + toSnakeCase(s) + "_fail_count",
-				Help: "Jenkins build fail counts for " + s,
-			},
+		jobDescs[s+"FailCounts"] = newDesc(
+			"jenkins_job_"+toSnakeCase(s)+"_fail_count",
+			"Jenkins build fail counts for "+s,
 			[]string{
 				"jobname",
 			},
 		)
 		// Pass counts
-		prometheusMetrics[s+"PassCounts"] = promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: "jenkins_job_" + toSnakeCase(s) + "_pass_count",
-				Help: "Jenkins build pass counts for " + s,
-			},
+		jobDescs[s+"PassCounts"] = newDesc(
+			"jenkins_job_"+toSnakeCase(s)+"_pass_count",
+			"Jenkins build pass counts for "+s,
 			[]string{
 				"jobname",
 			},
 		)
 		// Total counts
-		prometheusMetrics[s+"TotalCounts"] = promauto.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: "jenkins_job_" + toSnakeCase(s) + "_total_count",
-				Help: "Jenkins build total counts for " + s,
+		jobDescs[s+"TotalCounts"] = newDesc(
+			"jenkins_job_"+toSnakeCase(s)+"_total_count",
+			"Jenkins build total counts for "+s,
+			[]string{
+				"jobname",
 			},
+		)
+	}
+	// Result state sets
+	for _, s := range resultStatuses {
+		jobDescs[s+"Result"] = newDesc(
+			"jenkins_job_"+toSnakeCase(s)+"_result",
+			"Jenkins build result for "+s+", 1 for the current result and 0 for the others",
 			[]string{
 				"jobname",
+				"result",
 			},
 		)
 	}
+	// Building
+	jobDescs["building"] = newDesc(
+		"jenkins_job_building",
+		"Whether the last build of the job is still running",
+		[]string{
+			"jobname",
+		},
+	)
+	// Health score
+	jobDescs["healthScore"] = newDesc(
+		"jenkins_job_health_score",
+		"Jenkins job weather score, from 0 to 100",
+		[]string{
+			"jobname",
+		},
+	)
+	// Disabled
+	jobDescs["disabled"] = newDesc(
+		"jenkins_job_disabled",
+		"Whether the job is disabled",
+		[]string{
+			"jobname",
+		},
+	)
+	// Buildable
+	jobDescs["buildable"] = newDesc(
+		"jenkins_job_buildable",
+		"Whether the job can be built",
+		[]string{
+			"jobname",
+		},
+	)
+	// In queue
+	jobDescs["inQueue"] = newDesc(
+		"jenkins_job_in_queue",
+		"Whether the job has a build waiting in the queue",
+		[]string{
+			"jobname",
+		},
+	)
 }
 
-// Get data from Jenkins and update prometheus metrics
-func SetGauges() {
-	logrus.Debug("Launching metrics update loop: updating rate is set to ", config.Global.MetricsUpdateRate)
-	for {
-		var jResp *[]job = GetData()
-		for _, job := range *jResp {
-			jobMetrics := prepareMetrics(&job)
-			for _, s := range jobStatuses {
-				for _, p := range jobStatusProperties {
-					prometheusMetrics[s+p].With(prometheus.Labels{"jobname": job.FullName}).Set(jobMetrics[s+p])
-				}
-			}
-		}
-		time.Sleep(config.Global.MetricsUpdateRate)
+// Exporter self-monitoring metrics of the requests made to jenkins, updated
+// as they are made
+var (
+	apiRequestsOpts = prometheus.CounterOpts{
+		Name: "jenkins_exporter_api_requests_total",
+		Help: "Requests made to the jenkins API, by HTTP status code or error kind",
+	}
+	apiRequestDurationOpts = prometheus.HistogramOpts{
+		Name:    "jenkins_exporter_api_request_duration_seconds",
+		Help:    "Latency of the requests made to the jenkins API",
+		Buckets: prometheus.DefBuckets,
 	}
+	decodeErrorsOpts = prometheus.CounterOpts{
+		Name: "jenkins_exporter_decode_errors_total",
+		Help: "Jenkins API replies that could not be decoded",
+	}
+	apiRequests = promauto.NewCounterVec(
+		apiRequestsOpts,
+		[]string{
+			"controller",
+			"code",
+		},
+	)
+	apiRequestDuration = promauto.NewHistogramVec(
+		apiRequestDurationOpts,
+		[]string{
+			"controller",
+		},
+	)
+	decodeErrors = promauto.NewCounterVec(
+		decodeErrorsOpts,
+		[]string{
+			"controller",
+		},
+	)
+)
+
+// apiMetrics are the self-monitoring metrics of the requests made to a
+// target, with every label but the code set
+type apiMetrics struct {
+	requests     *prometheus.CounterVec // By code
+	duration     prometheus.ObserverVec
+	decodeErrors *prometheus.CounterVec
 }
 
-func prepareMetrics(job *job) map[string]float64 {
-	var jobMetrics = make(map[string]float64, 100)
-	// LastBuild
-	jobMetrics["lastBuildNumber"] = i2F64(job.LastBuild.Number)
-	jobMetrics["lastBuildDuration"] = i2F64(job.LastBuild.Duration) / 1000.0
-	jobMetrics["lastBuildTimestamp"] = i2F64(job.LastBuild.Timestamp) / 1000.0
-	if len(job.LastBuild.Actions) == 1 {
-		jobMetrics["lastBuildQueuingDurationMillis"] = i2F64(job.LastBuild.Actions[0].QueuingDurationMillis) / 1000
-		jobMetrics["lastBuildTotalDurationMillis"] = i2F64(job.LastBuild.Actions[0].TotalDurationMillis) / 1000
-		jobMetrics["lastBuildSkipCount"] = i2F64(job.LastBuild.Actions[0].SkipCount)
-		jobMetrics["lastBuildFailCount"] = i2F64(job.LastBuild.Actions[0].FailCount)
-		jobMetrics["lastBuildTotalCount"] = i2F64(job.LastBuild.Actions[0].TotalCount)
-		jobMetrics["lastBuildPassCount"] = i2F64(job.LastBuild.Actions[0].PassCount)
+// controllerAPIMetrics returns the metrics of a configured target, in the
+// default registry
+func controllerAPIMetrics(name string) *apiMetrics {
+	labels := prometheus.Labels{"controller": name}
+	return &apiMetrics{
+		requests:     apiRequests.MustCurryWith(labels),
+		duration:     apiRequestDuration.MustCurryWith(labels),
+		decodeErrors: decodeErrors.MustCurryWith(labels),
 	}
-	// LastCompletedBuild
-	jobMetrics["lastCompletedBuildNumber"] = i2F64(job.LastCompletedBuild.Number)
-	jobMetrics["lastCompletedBuildDuration"] = i2F64(job.LastCompletedBuild.Duration) / 1000
-	jobMetrics["lastCompletedBuildTimestamp"] = i2F64(job.LastCompletedBuild.Timestamp) / 1000
-	if len(job.LastCompletedBuild.Actions) == 1 {
-		jobMetrics["lastCompletedBuildQueuingDurationMillis"] = i2F64(job.LastCompletedBuild.Actions[0].QueuingDurationMillis) / 1000
-		jobMetrics["lastCompletedBuildTotalDurationMillis"] = i2F64(job.LastCompletedBuild.Actions[0].TotalDurationMillis) / 1000
-		jobMetrics["lastCompletedBuildSkipCount"] = i2F64(job.LastCompletedBuild.Actions[0].SkipCount)
-		jobMetrics["lastCompletedBuildFailCount"] = i2F64(job.LastCompletedBuild.Actions[0].FailCount)
-		jobMetrics["lastCompletedBuildTotalCount"] = i2F64(job.LastCompletedBuild.Actions[0].TotalCount)
-		jobMetrics["lastCompletedBuildPassCount"] = i2F64(job.LastCompletedBuild.Actions[0].PassCount)
+}
+
+// probeAPIMetrics returns metrics of their own for a probe, registered with
+// reg, so that probing arbitrary URLs does not add series to the default
+// registry
+func probeAPIMetrics(reg prometheus.Registerer) *apiMetrics {
+	m := &apiMetrics{
+		requests:     prometheus.NewCounterVec(apiRequestsOpts, []string{"code"}),
+		duration:     prometheus.NewHistogramVec(apiRequestDurationOpts, nil),
+		decodeErrors: prometheus.NewCounterVec(decodeErrorsOpts, nil),
 	}
-	// LastFailedBuild
-	jobMetrics["lastFailedBuildNumber"] = i2F64(job.LastFailedBuild.Number)
-	jobMetrics["lastFailedBuildDuration"] = i2F64(job.LastFailedBuild.Duration) / 1000
-	jobMetrics["lastFailedBuildTimestamp"] = i2F64(job.LastFailedBuild.Timestamp) / 1000
-	if len(job.LastFailedBuild.Actions) == 1 {
-		jobMetrics["lastFailedBuildQueuingDurationMillis"] = i2F64(job.LastFailedBuild.Actions[0].QueuingDurationMillis) / 1000
-		jobMetrics["lastFailedBuildTotalDurationMillis"] = i2F64(job.LastFailedBuild.Actions[0].TotalDurationMillis) / 1000
-		jobMetrics["lastFailedBuildSkipCount"] = i2F64(job.LastFailedBuild.Actions[0].SkipCount)
-		jobMetrics["lastFailedBuildFailCount"] = i2F64(job.LastFailedBuild.Actions[0].FailCount)
-		jobMetrics["lastFailedBuildTotalCount"] = i2F64(job.LastFailedBuild.Actions[0].TotalCount)
-		jobMetrics["lastFailedBuildPassCount"] = i2F64(job.LastFailedBuild.Actions[0].PassCount)
+	reg.MustRegister(m.requests, m.duration, m.decodeErrors)
+	return m
+}
+
+// Exporter self-monitoring metrics of the configuration reloads
+var (
+	configReloads = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jenkins_exporter_config_reloads_total",
+			Help: "Configuration reloads, by result: success or failure",
+		},
+		[]string{
+			"result",
+		},
+	)
+	configLastReloadOK = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "jenkins_exporter_config_last_reload_successful",
+			Help: "Whether the last configuration reload succeeded",
+		},
+	)
+	configLastReloadTime = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "jenkins_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Time of the last successful configuration load in unixtime",
+		},
+	)
+)
+
+// Exporter self-monitoring metrics, taken from the snapshot
+var (
+	upDesc = newDesc(
+		"jenkins_up",
+		"Whether the last crawl of jenkins succeeded",
+		nil,
+	)
+	crawlDurationDesc = newDesc(
+		"jenkins_exporter_crawl_duration_seconds",
+		"Duration of the last crawl of the jenkins jobs",
+		nil,
+	)
+	jobsFoundDesc = newDesc(
+		"jenkins_exporter_jobs_discovered",
+		"Number of jobs found by the last crawl",
+		nil,
+	)
+	foldersFoundDesc = newDesc(
+		"jenkins_exporter_folders_discovered",
+		"Number of folders explored by the last crawl",
+		nil,
+	)
+	lastSuccessDesc = newDesc(
+		"jenkins_exporter_last_successful_crawl_timestamp_seconds",
+		"Time of the last successful crawl of jenkins in unixtime",
+		nil,
+	)
+)
+
+// Every descriptor of the metrics exported from jenkins
+var allDescs []*prometheus.Desc
+
+// newDesc creates the descriptor of a metric exported from jenkins
+func newDesc(name, help string, labels []string) *prometheus.Desc {
+	desc := prometheus.NewDesc(name, help, labels, nil)
+	allDescs = append(allDescs, desc)
+	return desc
+}
+
+// jenkinsCollector is a prometheus.Collector that builds the metrics at
+// scrape time, from the last snapshot published by a crawler. There is one
+// per target, registered with its controller label, and one per probe.
+type jenkinsCollector struct {
+	crawler *crawler
+	probe   bool // Crawled by the probe before the scrape, whatever the configuration
+}
+
+func newJenkinsCollector(c *crawler) *jenkinsCollector {
+	return &jenkinsCollector{crawler: c}
+}
+
+// Describe implements prometheus.Collector
+func (c *jenkinsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range allDescs {
+		ch <- desc
 	}
-	// LastStableBuild
-	jobMetrics["lastStableBuildNumber"] = i2F64(job.LastStableBuild.Number)
-	jobMetrics["lastStableBuildDuration"] = i2F64(job.LastStableBuild.Duration) / 1000
-	jobMetrics["lastStableBuildTimestamp"] = i2F64(job.LastStableBuild.Timestamp) / 1000
-	if len(job.LastStableBuild.Actions) == 1 {
-		jobMetrics["lastStableBuildQueuingDurationMillis"] = i2F64(job.LastStableBuild.Actions[0].QueuingDurationMillis) / 1000
-		jobMetrics["lastStableBuildTotalDurationMillis"] = i2F64(job.LastStableBuild.Actions[0].TotalDurationMillis) / 1000
-		jobMetrics["lastStableBuildSkipCount"] = i2F64(job.LastStableBuild.Actions[0].SkipCount)
-		jobMetrics["lastStableBuildFailCount"] = i2F64(job.LastStableBuild.Actions[0].FailCount)
-		jobMetrics["lastStableBuildTotalCount"] = i2F64(job.LastStableBuild.Actions[0].TotalCount)
-		jobMetrics["lastStableBuildPassCount"] = i2F64(job.LastStableBuild.Actions[0].PassCount)
+}
+
+// Collect implements prometheus.Collector
+func (c *jenkinsCollector) Collect(ch chan<- prometheus.Metric) {
+	var snap *snapshot
+	if !c.probe && config.Get().CrawlOnScrape {
+		snap = c.crawler.refresh()
+	} else {
+		snap = c.crawler.snapshot()
 	}
-	// LastSuccessfulBuild
-	jobMetrics["lastSuccessfulBuildNumber"] = i2F64(job.LastSuccessfulBuild.Number)
-	jobMetrics["lastSuccessfulBuildDuration"] = i2F64(job.LastSuccessfulBuild.Duration) / 1000
-	jobMetrics["lastSuccessfulBuildTimestamp"] = i2F64(job.LastSuccessfulBuild.Timestamp) / 1000
-	if len(job.LastSuccessfulBuild.Actions) == 1 {
-		jobMetrics["lastSuccessfulBuildQueuingDurationMillis"] = i2F64(job.LastSuccessfulBuild.Actions[0].QueuingDurationMillis) / 1000
-		jobMetrics["lastSuccessfulBuildTotalDurationMillis"] = i2F64(job.LastSuccessfulBuild.Actions[0].TotalDurationMillis) / 1000
-		jobMetrics["lastSuccessfulBuildSkipCount"] = i2F64(job.LastSuccessfulBuild.Actions[0].SkipCount)
-		jobMetrics["lastSuccessfulBuildFailCount"] = i2F64(job.LastSuccessfulBuild.Actions[0].FailCount)
-		jobMetrics["lastSuccessfulBuildTotalCount"] = i2F64(job.LastSuccessfulBuild.Actions[0].TotalCount)
-		jobMetrics["lastSuccessfulBuildPassCount"] = i2F64(job.LastSuccessfulBuild.Actions[0].PassCount)
+	// Nothing to export before the first update cycle is over
+	if snap == nil {
+		return
 	}
-	// LastUnstableBuild
-	jobMetrics["lastUnstableBuildNumber"] = i2F64(job.LastUnstableBuild.Number)
-	jobMetrics["lastUnstableBuildDuration"] = i2F64(job.LastUnstableBuild.Duration) / 1000
-	jobMetrics["lastUnstableBuildTimestamp"] = i2F64(job.LastUnstableBuild.Timestamp) / 1000
-	if len(job.LastUnstableBuild.Actions) == 1 {
-		jobMetrics["lastUnstableBuildQueuingDurationMillis"] = i2F64(job.LastUnstableBuild.Actions[0].QueuingDurationMillis) / 1000
-		jobMetrics["lastUnstableBuildTotalDurationMillis"] = i2F64(job.LastUnstableBuild.Actions[0].TotalDurationMillis) / 1000
-		jobMetrics["lastUnstableBuildSkipCount"] = i2F64(job.LastUnstableBuild.Actions[0].SkipCount)
-		jobMetrics["lastUnstableBuildFailCount"] = i2F64(job.LastUnstableBuild.Actions[0].FailCount)
-		jobMetrics["lastUnstableBuildTotalCount"] = i2F64(job.LastUnstableBuild.Actions[0].TotalCount)
-		jobMetrics["lastUnstableBuildPassCount"] = i2F64(job.LastUnstableBuild.Actions[0].PassCount)
+	collectSelf(ch, snap)
+	collectJobs(ch, snap)
+	collectHistory(ch, snap)
+	collectPipelines(ch, snap)
+	collectTestReports(ch, snap)
+	collectQueue(ch, snap)
+	collectComputers(ch, snap)
+	collectPlugins(ch, snap)
+}
+
+func collectSelf(ch chan<- prometheus.Metric, snap *snapshot) {
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, b2F64(snap.up))
+	ch <- prometheus.MustNewConstMetric(crawlDurationDesc, prometheus.GaugeValue, snap.crawlDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(jobsFoundDesc, prometheus.GaugeValue, i2F64(snap.jobsFound))
+	ch <- prometheus.MustNewConstMetric(foldersFoundDesc, prometheus.GaugeValue, i2F64(snap.foldersFound))
+	if !snap.lastSuccessAt.IsZero() {
+		ch <- prometheus.MustNewConstMetric(lastSuccessDesc, prometheus.GaugeValue, float64(snap.lastSuccessAt.UnixNano())/1e9)
 	}
-	// LastUnsuccessfulBuild
-	jobMetrics["lastUnsuccessfulBuildNumber"] = i2F64(job.LastUnsuccessfulBuild.Number)
-	jobMetrics["lastUnsuccessfulBuildDuration"] = i2F64(job.LastUnsuccessfulBuild.Duration) / 1000
-	jobMetrics["lastUnsuccessfulBuildTimestamp"] = i2F64(job.LastUnsuccessfulBuild.Timestamp) / 1000
-	if len(job.LastUnsuccessfulBuild.Actions) == 1 {
-		jobMetrics["lastUnsuccessfulBuildQueuingDurationMillis"] = i2F64(job.LastUnsuccessfulBuild.Actions[0].QueuingDurationMillis) / 1000
-		jobMetrics["lastUnsuccessfulBuildTotalDurationMillis"] = i2F64(job.LastUnsuccessfulBuild.Actions[0].TotalDurationMillis) / 1000
-		jobMetrics["lastUnsuccessfulBuildSkipCount"] = i2F64(job.LastUnsuccessfulBuild.Actions[0].SkipCount)
-		jobMetrics["lastUnsuccessfulBuildFailCount"] = i2F64(job.LastUnsuccessfulBuild.Actions[0].FailCount)
-		jobMetrics["lastUnsuccessfulBuildTotalCount"] = i2F64(job.LastUnsuccessfulBuild.Actions[0].TotalCount)
-		jobMetrics["lastUnsuccessfulBuildPassCount"] = i2F64(job.LastUnsuccessfulBuild.Actions[0].PassCount)
+}
+
+func collectJobs(ch chan<- prometheus.Metric, snap *snapshot) {
+	for i := range snap.jobs {
+		job := &snap.jobs[i]
+		jobMetrics := prepareMetrics(job)
+		for _, s := range jobStatuses {
+			for _, p := range jobStatusProperties {
+				// Builds or actions the job does not have are left out,
+				// publishing zeros would pass them for real values
+				if v, ok := jobMetrics[s+p]; ok {
+					ch <- prometheus.MustNewConstMetric(jobDescs[s+p], prometheus.GaugeValue, v, job.FullName)
+				}
+			}
+		}
+		for _, s := range resultStatuses {
+			status := job.status(s)
+			if status == nil {
+				continue
+			}
+			for _, r := range buildResults {
+				ch <- prometheus.MustNewConstMetric(jobDescs[s+"Result"], prometheus.GaugeValue, b2F64(status.Result == r), job.FullName, r)
+			}
+		}
+		if job.LastBuild != nil {
+			ch <- prometheus.MustNewConstMetric(jobDescs["building"], prometheus.GaugeValue, b2F64(job.LastBuild.Building), job.FullName)
+		}
+		ch <- prometheus.MustNewConstMetric(jobDescs["disabled"], prometheus.GaugeValue, b2F64(job.isDisabled()), job.FullName)
+		ch <- prometheus.MustNewConstMetric(jobDescs["buildable"], prometheus.GaugeValue, b2F64(job.Buildable), job.FullName)
+		ch <- prometheus.MustNewConstMetric(jobDescs["inQueue"], prometheus.GaugeValue, b2F64(job.InQueue), job.FullName)
+		if score, ok := job.healthScore(); ok {
+			ch <- prometheus.MustNewConstMetric(jobDescs["healthScore"], prometheus.GaugeValue, i2F64(score), job.FullName)
+		}
 	}
+}
 
+func prepareMetrics(job *job) map[string]float64 {
+	var jobMetrics = make(map[string]float64, 100)
+	for _, s := range jobStatuses {
+		status := job.status(s)
+		if status == nil {
+			continue
+		}
+		jobMetrics[s+"Number"] = i2F64(status.Number)
+		jobMetrics[s+"Duration"] = i2F64(status.Duration) / 1000
+		jobMetrics[s+"EstimatedDuration"] = i2F64(status.EstimatedDuration) / 1000
+		jobMetrics[s+"Timestamp"] = i2F64(status.Timestamp) / 1000
+		if queue := status.Actions.Queue; queue != nil {
+			jobMetrics[s+"QueuingDuration"] = i2F64(queue.QueuingDurationMillis) / 1000
+			jobMetrics[s+"TotalDuration"] = i2F64(queue.TotalDurationMillis) / 1000
+		}
+		if tests := status.Actions.Tests; tests != nil {
+			jobMetrics[s+"SkipCounts"] = i2F64(tests.SkipCount)
+			jobMetrics[s+"FailCounts"] = i2F64(tests.FailCount)
+			jobMetrics[s+"TotalCounts"] = i2F64(tests.TotalCount)
+			jobMetrics[s+"PassCounts"] = i2F64(tests.PassCount())
+		}
+	}
 	return jobMetrics
 }
 
@@ -221,6 +396,7 @@ var jobStatusProperties = []string{
 	"Number",
 	"Timestamp",
 	"Duration",
+	"EstimatedDuration",
 	"QueuingDuration",
 	"TotalDuration",
 	"SkipCounts",
@@ -233,6 +409,13 @@ func i2F64(i int) float64 {
 	return float64(i)
 }
 
+func b2F64(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 // Thanks to https://gist.github.com/stoewer/fbe273b711e6a06315d19552dd4d33e6
 var matchFirstCap = regexp.MustCompile("(.)([A-Z][a-z]+)")
 var matchAllCap = regexp.MustCompile("([a-z0-9])([A-Z])")
@@ -0,0 +1,120 @@
+package exporter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Jenkins queue item struct
+type queueItem struct {
+	Class        string `json:"_class"`
+	ID           int    `json:"id"`
+	Blocked      bool   `json:"blocked"`
+	Buildable    bool   `json:"buildable"`
+	Stuck        bool   `json:"stuck"`
+	InQueueSince int64  `json:"inQueueSince"`
+	Why          string `json:"why"`
+	Task         struct {
+		Name     string `json:"name"`
+		FullName string `json:"fullName"`
+		URL      string `json:"url"`
+	} `json:"task"`
+}
+
+// Jenkins queue API response struct
+type queueResponse struct {
+	Items []queueItem `json:"items"`
+}
+
+// jobName returns the name of the job the item will build
+func (q *queueItem) jobName() string {
+	if q.Task.FullName != "" {
+		return q.Task.FullName
+	}
+	return q.Task.Name
+}
+
+// GetQueue returns the items waiting in the build queue of a jenkins target
+func GetQueue(t *target) ([]queueItem, error) {
+	logrus.Debug("Get queue from jenkins ", t.name, "..")
+	var qResp queueResponse
+	err := getJSON(t, t.url+"queue/api/json?tree=items[id,blocked,buildable,stuck,inQueueSince,why,task[name,fullName,url]]", &qResp)
+	if err != nil {
+		return nil, err
+	}
+	return qResp.Items, nil
+}
+
+// Queue reasons are free text, match them against the messages jenkins
+// core uses to put them in a handful of categories. The first match wins.
+var queueReasons = []struct {
+	match    string
+	category string
+}{
+	{"quiet period", "quiet_period"},
+	{"is already in progress", "build_in_progress"},
+	{"Waiting for next available executor", "waiting_for_executor"},
+	{"is offline", "node_offline"},
+	{"are offline", "node_offline"},
+	{"There are no nodes", "no_node"},
+	{"doesn’t have label", "no_node"},
+	{"doesn't have label", "no_node"},
+	{"is reserved for jobs", "no_node"},
+	{"project is building", "blocked_by_project"},
+	{"Blocked by", "blocked_by_project"},
+	{"blocked by", "blocked_by_project"},
+	{"about to shut down", "shutting_down"},
+	{"Waiting for", "waiting_for_resource"},
+}
+
+// reasonCategory maps the "why" of a queue item to its category
+func reasonCategory(why string) string {
+	if why == "" {
+		return "none"
+	}
+	for _, r := range queueReasons {
+		if strings.Contains(why, r.match) {
+			return r.category
+		}
+	}
+	return "other"
+}
+
+var queueLabels = []string{"jobname", "blocked", "stuck", "buildable", "reason"}
+
+var queueItemsDesc = newDesc(
+	"jenkins_queue_items",
+	"Number of items in the jenkins build queue",
+	queueLabels,
+)
+
+var queueItemWaitingDesc = newDesc(
+	"jenkins_queue_item_waiting_seconds",
+	"Time spent in the jenkins build queue by each queued item",
+	append([]string{"id"}, queueLabels...),
+)
+
+func collectQueue(ch chan<- prometheus.Metric, snap *snapshot) {
+	counts := make(map[[5]string]int)
+	now := time.Now()
+	for _, i := range snap.queue {
+		labels := [5]string{
+			i.jobName(),
+			strconv.FormatBool(i.Blocked),
+			strconv.FormatBool(i.Stuck),
+			strconv.FormatBool(i.Buildable),
+			reasonCategory(i.Why),
+		}
+		counts[labels]++
+		waiting := now.Sub(time.Unix(0, i.InQueueSince*int64(time.Millisecond)))
+		ch <- prometheus.MustNewConstMetric(queueItemWaitingDesc, prometheus.GaugeValue, waiting.Seconds(),
+			append([]string{strconv.Itoa(i.ID)}, labels[:]...)...)
+	}
+	for labels, n := range counts {
+		ch <- prometheus.MustNewConstMetric(queueItemsDesc, prometheus.GaugeValue, i2F64(n), labels[:]...)
+	}
+}
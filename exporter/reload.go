@@ -0,0 +1,103 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/goodbins/go-jenkins-exporter/config"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Loader builds a new configuration from the same sources and checks it
+// with the same rules as at startup
+type Loader func() (config.Config, error)
+
+// Serializes reloads
+var reloadMu sync.Mutex
+
+// Delay without config file events before reloading it
+const configSettleDelay = time.Second
+
+// watchConfig reloads the configuration on SIGHUP and whenever the config
+// file changes
+func watchConfig(load Loader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reload(load, "SIGHUP")
+		}
+	}()
+
+	if path := config.Get().ConfigFile; path != "" {
+		v := viper.New()
+		v.SetConfigFile(path)
+		// Files are often written in several steps, each one with its own
+		// event: wait for the last one not to load a partial file
+		var settle *time.Timer
+		v.OnConfigChange(func(e fsnotify.Event) {
+			if settle != nil {
+				settle.Stop()
+			}
+			settle = time.AfterFunc(configSettleDelay, func() {
+				reload(load, "change of "+path)
+			})
+		})
+		v.WatchConfig()
+	}
+}
+
+// reload swaps in a new configuration, or keeps the current one if the new
+// one is invalid
+func reload(load Loader, trigger string) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	logrus.Info("Reloading the configuration on ", trigger)
+	cfg, err := load()
+	if err == nil {
+		err = applyConfig(cfg)
+	}
+	if err != nil {
+		logrus.Error("Could not reload the configuration, keeping the current one: ", err)
+		configReloads.WithLabelValues("failure").Inc()
+		configLastReloadOK.Set(0)
+		return
+	}
+	logrus.Info("Configuration reloaded")
+	configReloads.WithLabelValues("success").Inc()
+	configLastReloadOK.Set(1)
+	configLastReloadTime.SetToCurrentTime()
+}
+
+// applyConfig makes cfg the configuration in use, along with the targets
+// built from it. The next requests and update cycles use them.
+func applyConfig(cfg config.Config) error {
+	prev := config.Get()
+	targets := make([]*target, 0, len(cfg.Targets))
+	for _, tc := range cfg.Targets {
+		t, err := newTarget(&cfg, tc)
+		if err != nil {
+			return fmt.Errorf("target %s: %v", tc.Name, err)
+		}
+		targets = append(targets, t)
+	}
+	// The server is already listening
+	if cfg.ExporterHostPort != prev.ExporterHostPort {
+		logrus.Warn("The listen address can't be changed by a reload, restart the exporter to listen on ", cfg.ExporterHostPort)
+		cfg.ExporterHostPort = prev.ExporterHostPort
+	}
+	if cfg.WebConfigFile != prev.WebConfigFile {
+		logrus.Warn("The web config file can't be changed by a reload, restart the exporter to use ", cfg.WebConfigFile)
+		cfg.WebConfigFile = prev.WebConfigFile
+	}
+	config.Set(cfg)
+	controllers.apply(targets)
+	config.SetupLogging()
+	return nil
+}
@@ -0,0 +1,64 @@
+package exporter
+
+import (
+	"sort"
+	"time"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+)
+
+// retainJobs returns the jobs to export after a crawl, sorted by full name,
+// along with the last time each of them was seen.
+//
+// Jobs missing from a successful crawl, that is deleted, renamed or moved
+// jobs and ephemeral branches, are dropped right away, unless a series TTL
+// is set: they are then kept until not seen for that long. When the crawl
+// failed, the jobs of the previous snapshot are kept, and expire after the
+// TTL too if one is set.
+func retainJobs(prev *snapshot, crawled []job, crawlOK bool, now time.Time) ([]job, map[string]time.Time) {
+	ttl := config.Get().SeriesTTL
+	jobs := make([]job, 0, len(crawled))
+	seen := make(map[string]time.Time, len(crawled))
+	for _, j := range crawled {
+		jobs = append(jobs, j)
+		seen[j.FullName] = now
+	}
+	if crawlOK && ttl <= 0 {
+		return jobs, seen
+	}
+	for _, j := range prev.jobs {
+		if _, ok := seen[j.FullName]; ok {
+			continue
+		}
+		at := prev.jobsSeen[j.FullName]
+		if ttl > 0 && now.Sub(at) > ttl {
+			continue
+		}
+		jobs = append(jobs, j)
+		seen[j.FullName] = at
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].FullName < jobs[k].FullName
+	})
+	return jobs, seen
+}
+
+// expire drops the queue, nodes and plugins that could not be refreshed for
+// longer than the series TTL, so series don't outlive a dead controller
+func (s *snapshot) expire(now time.Time) {
+	ttl := config.Get().SeriesTTL
+	if ttl <= 0 {
+		return
+	}
+	if now.Sub(s.queueAt) > ttl {
+		s.queue = nil
+	}
+	if now.Sub(s.computersAt) > ttl {
+		s.computers = nil
+	}
+	// Plugins are only fetched at their own update rate, they expire the
+	// TTL after a fetch was due
+	if now.Sub(s.pluginsAt) > config.Get().PluginsUpdateRate+ttl {
+		s.plugins = nil
+	}
+}
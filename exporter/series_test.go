@@ -0,0 +1,75 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetainJobs(t *testing.T) {
+	now := time.Now()
+	prev := &snapshot{
+		jobs: []job{{FullName: "a"}, {FullName: "gone-recently"}, {FullName: "gone-long-ago"}},
+		jobsSeen: map[string]time.Time{
+			"a":             now.Add(-time.Minute),
+			"gone-recently": now.Add(-5 * time.Minute),
+			"gone-long-ago": now.Add(-time.Hour),
+		},
+	}
+	crawled := []job{{FullName: "a"}, {FullName: "b"}} // Sorted, as GetData returns them
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		crawlOK bool
+		want    []string
+	}{
+		{"no TTL, crawl OK", 0, true, []string{"a", "b"}},
+		{"no TTL, crawl failed", 0, false, []string{"a", "b", "gone-long-ago", "gone-recently"}},
+		{"TTL, crawl OK", 10 * time.Minute, true, []string{"a", "b", "gone-recently"}},
+		{"TTL, crawl failed", 10 * time.Minute, false, []string{"a", "b", "gone-recently"}},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		cfg.SeriesTTL = tt.ttl
+		restore := useConfig(cfg)
+		jobs, seen := retainJobs(prev, crawled, tt.crawlOK, now)
+		restore()
+
+		var names []string
+		for _, j := range jobs {
+			names = append(names, j.FullName)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%s: jobs = %v, want %v", tt.name, names, tt.want)
+		}
+		if !seen["a"].Equal(now) || !seen["b"].Equal(now) {
+			t.Errorf("%s: crawled jobs not seen now: %v", tt.name, seen)
+		}
+		if at, ok := seen["gone-recently"]; ok && !at.Equal(prev.jobsSeen["gone-recently"]) {
+			t.Errorf("%s: retained job seen at %s, want %s", tt.name, at, prev.jobsSeen["gone-recently"])
+		}
+	}
+}
+
+func TestExpireKeepsPluginsUntilTheirFetchIsOverdue(t *testing.T) {
+	cfg := testConfig()
+	cfg.SeriesTTL = 10 * time.Minute
+	cfg.PluginsUpdateRate = time.Hour
+	defer useConfig(cfg)()
+	now := time.Now()
+	tests := []struct {
+		age  time.Duration
+		kept bool
+	}{
+		{30 * time.Minute, true},
+		{65 * time.Minute, true},
+		{75 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		s := &snapshot{plugins: []plugin{{}}, pluginsAt: now.Add(-tt.age), queueAt: now, computersAt: now}
+		s.expire(now)
+		if (s.plugins != nil) != tt.kept {
+			t.Errorf("plugins fetched %s ago: kept %v, want %v", tt.age, s.plugins != nil, tt.kept)
+		}
+	}
+}
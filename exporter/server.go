@@ -8,27 +8,64 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// Serve serves the metrics, helthcheck /ping and a redirection on /
-func Serve() {
+// Serve serves the metrics, helthcheck /ping, liveness and readiness on
+// /-/healthy and /-/ready, and a redirection on /. The configuration is
+// reloaded with load on SIGHUP and config file changes.
+func Serve(load Loader) {
 	// Print start message
 	logrus.Info("Starting go-jenkins-exporter")
+	// Protect the endpoints as the web config file asks
+	var web *webSecurity
+	if path := config.Global.WebConfigFile; path != "" {
+		var err error
+		if web, err = newWebSecurity(path); err != nil {
+			logrus.Fatal("Could not load the web config: ", err)
+		}
+	}
+	// Crawl the targets and export the metrics of their snapshots
+	if err := applyConfig(config.Global); err != nil {
+		logrus.Fatal("Could not setup the jenkins targets: ", err)
+	}
+	configLastReloadOK.Set(1)
+	configLastReloadTime.SetToCurrentTime()
+	watchConfig(load)
 
-	// Launch metrics update go routine
-	go SetGauges()
-
-	// Handle routes: / /ping /metrics
+	// Handle routes: / /ping /-/healthy /-/ready /probe /metrics, the metrics path may be
+	// changed by a reload
+	metrics := promhttp.Handler()
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		metricsPath := config.Get().MetricsPath
+		if r.URL.Path == metricsPath {
+			metrics.ServeHTTP(w, r)
+			return
+		}
 		w.Write([]byte(`<html>
 		<head><title>Go Jenkins Exporter</title></head>
 		<body>
 		<h1>Go Jenkins Exporter</h1>
-		<p><a href="` + config.Global.MetricsPath + `">Metrics</a></p>
+		<p><a href="` + metricsPath + `">Metrics</a></p>
 		</body></html>`))
 	})
 	http.HandleFunc("/ping", Ping)
-	http.Handle(config.Global.MetricsPath, promhttp.Handler())
+	http.HandleFunc("/-/healthy", Healthy)
+	http.HandleFunc("/-/ready", Ready)
+	http.HandleFunc("/probe", Probe)
 
 	// Listen and serve
-	logrus.Info("Listning on " + config.Global.ExporterHostPort + " ...")
-	logrus.Fatal(http.ListenAndServe(config.Global.ExporterHostPort, nil))
+	logrus.Info("Listning on " + config.Get().ExporterHostPort + " ...")
+	logrus.Fatal(listenAndServe(config.Get().ExporterHostPort, web))
+}
+
+// listenAndServe serves the routes, over TLS and behind authentication if
+// the web config file asks for it
+func listenAndServe(addr string, web *webSecurity) error {
+	if web == nil {
+		return http.ListenAndServe(addr, nil)
+	}
+	server := &http.Server{Addr: addr, Handler: web.handler(http.DefaultServeMux)}
+	if !web.tls {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = web.tlsConfig()
+	return server.ListenAndServeTLS("", "")
 }
@@ -0,0 +1,208 @@
+package exporter
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+	"github.com/sirupsen/logrus"
+)
+
+// snapshot is everything an update cycle got from jenkins. It is built from
+// the previous snapshot and the replies of jenkins, and never modified once
+// published, so scrapes always see a consistent set of metrics.
+type snapshot struct {
+	jobs           []job                // Sorted by full name
+	jobsSeen       map[string]time.Time // Last crawl each job was found in
+	histograms     map[string]*buildHistogram
+	observedBuilds map[string]map[int]bool // Builds counted in the histograms
+	pipelineRuns   map[string]cachedRun
+	testReports    map[string]cachedReport
+	queue          []queueItem
+	queueAt        time.Time
+	computers      []computer
+	computersAt    time.Time
+	plugins        []plugin
+	pluginsAt      time.Time
+	updatedAt      time.Time
+
+	// Outcome of the last crawl
+	up            bool
+	crawlDuration time.Duration
+	jobsFound     int
+	foldersFound  int
+	finishedAt    time.Time     // End of the update cycle, when its data is served
+	lastSuccessAt time.Time     // End of the last successful update cycle
+	successCycle  time.Duration // Length of the last successful update cycle
+	lastError     string
+	lastErrorAt   time.Time
+}
+
+// crawler runs the update cycles of a target and publishes their snapshots
+type crawler struct {
+	mu      sync.Mutex    // Serializes update cycles
+	current atomic.Value  // Last published *snapshot
+	conf    atomic.Value  // *target, replaced by reloads
+	stop    chan struct{} // Closed to stop the update loop
+
+	flightMu sync.Mutex
+	flight   *flight // Update cycle shared by concurrent scrapes
+}
+
+// flight is an update cycle other callers can wait for
+type flight struct {
+	done chan struct{}
+	snap *snapshot
+}
+
+func newCrawler(t *target) *crawler {
+	c := &crawler{stop: make(chan struct{})}
+	c.conf.Store(t)
+	return c
+}
+
+// target returns the target crawled by the next update cycle
+func (c *crawler) target() *target {
+	return c.conf.Load().(*target)
+}
+
+// setTarget replaces the target, keeping the snapshot so that its series
+// carry on
+func (c *crawler) setTarget(t *target) {
+	prev := c.target()
+	c.conf.Store(t)
+	prev.closeIdleConnections()
+}
+
+// snapshot returns the last published snapshot, nil before the end of the
+// first update cycle
+func (c *crawler) snapshot() *snapshot {
+	snap, _ := c.current.Load().(*snapshot)
+	return snap
+}
+
+// run updates the snapshot at the metrics update rate, until halted. Both
+// can be changed by a reload, so no update is made while the metrics are
+// updated at scrape time instead, except for the first one: the exporter
+// is not ready before it.
+func (c *crawler) run() {
+	logrus.Debug("Launching metrics update loop of ", c.target().name, ": updating rate is set to ", config.Get().MetricsUpdateRate)
+	for first := true; ; first = false {
+		if first || !config.Get().CrawlOnScrape {
+			// Shared with the scrapes coming meanwhile
+			c.refresh()
+		}
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(config.Get().MetricsUpdateRate):
+		}
+	}
+}
+
+// halt stops the update loop, once the running update cycle is over
+func (c *crawler) halt() {
+	close(c.stop)
+}
+
+// update runs one update cycle and publishes its snapshot
+func (c *crawler) update() *snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev := c.snapshot()
+	if prev == nil {
+		prev = &snapshot{}
+	}
+	next := nextSnapshot(c.target(), prev)
+	c.current.Store(next)
+	return next
+}
+
+// refresh runs an update cycle, or waits for the one already running and
+// returns its snapshot, so that concurrent scrapes share a single crawl
+func (c *crawler) refresh() *snapshot {
+	c.flightMu.Lock()
+	if f := c.flight; f != nil {
+		c.flightMu.Unlock()
+		<-f.done
+		return f.snap
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flight = f
+	c.flightMu.Unlock()
+
+	f.snap = c.update()
+	c.flightMu.Lock()
+	c.flight = nil
+	c.flightMu.Unlock()
+	close(f.done)
+	return f.snap
+}
+
+// nextSnapshot gets fresh data from the jenkins target. Whatever can't be
+// retrieved is taken from the previous snapshot, so the last good metrics
+// are served until jenkins is back.
+func nextSnapshot(t *target, prev *snapshot) *snapshot {
+	now := time.Now()
+	next := &snapshot{updatedAt: now}
+
+	var crawled []job
+	jobs, folders, err := GetData(t)
+	next.crawlDuration = time.Since(now)
+	next.lastSuccessAt, next.successCycle = prev.lastSuccessAt, prev.successCycle
+	next.lastError, next.lastErrorAt = prev.lastError, prev.lastErrorAt
+	if err != nil {
+		// Errors were logged during the crawl
+		logrus.Error("Crawl of ", t.name, " failed, job metrics were not updated")
+		next.lastError = err.Error()
+	} else {
+		crawled = *jobs
+		next.up = true
+	}
+	next.jobsFound, next.foldersFound = len(*jobs), folders
+	next.jobs, next.jobsSeen = retainJobs(prev, crawled, err == nil, now)
+	// Histograms are dropped along with the history
+	if config.Get().BuildHistory > 0 {
+		next.histograms, next.observedBuilds = observeBuilds(prev, next.jobs)
+	}
+	if config.Get().PipelineStagesOn {
+		next.pipelineRuns = refreshPipelineRuns(t, next.jobs, prev.pipelineRuns)
+	}
+	if config.Get().TestReportsOn {
+		next.testReports = refreshTestReports(t, next.jobs, prev.testReports)
+	}
+
+	next.queue, next.queueAt = prev.queue, prev.queueAt
+	if queue, err := GetQueue(t); err == nil {
+		next.queue, next.queueAt = queue, now
+	} else {
+		logrus.Error("Could not get the build queue of ", t.name, ", queue metrics were not updated: ", err)
+	}
+
+	next.computers, next.computersAt = prev.computers, prev.computersAt
+	if computers, err := GetComputers(t); err == nil {
+		next.computers, next.computersAt = computers, now
+	} else {
+		logrus.Error("Could not get the nodes of ", t.name, ", node metrics were not updated: ", err)
+	}
+
+	next.plugins, next.pluginsAt = prev.plugins, prev.pluginsAt
+	// Plugins rarely change, poll them at their own slower rate
+	if config.Get().PluginsOn && now.Sub(prev.pluginsAt) >= config.Get().PluginsUpdateRate {
+		if plugins, err := GetPlugins(t); err == nil {
+			next.plugins, next.pluginsAt = plugins, now
+		} else {
+			logrus.Error("Could not get the plugins of ", t.name, ", plugin metrics were not updated: ", err)
+		}
+	}
+
+	next.expire(now)
+	next.finishedAt = time.Now()
+	if err != nil {
+		next.lastErrorAt = next.finishedAt
+	} else {
+		next.lastSuccessAt, next.successCycle = next.finishedAt, next.finishedAt.Sub(now)
+	}
+	return next
+}
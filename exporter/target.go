@@ -0,0 +1,82 @@
+package exporter
+
+import (
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strings"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+)
+
+// target is a jenkins controller as its crawler sees it: where it is, how
+// to connect to it and which of its jobs to export. It is built from the
+// configuration, and replaced as a whole when the configuration is reloaded.
+type target struct {
+	name    string // Value of the controller label
+	url     string // Base URL, with a trailing slash
+	creds   credentialProvider
+	client  *http.Client
+	include *regexp.Regexp // nil to export every job
+	exclude *regexp.Regexp // nil to exclude none
+	metrics *apiMetrics
+}
+
+func newTarget(cfg *config.Config, t config.Target) (*target, error) {
+	client, err := newClient(cfg, t)
+	if err != nil {
+		return nil, err
+	}
+	tg := &target{
+		name:    t.Name,
+		url:     t.URL,
+		creds:   newCredentialProvider(cfg, t),
+		client:  client,
+		metrics: controllerAPIMetrics(t.Name),
+	}
+	if t.IncludeJobs != "" {
+		if tg.include, err = regexp.Compile(t.IncludeJobs); err != nil {
+			return nil, err
+		}
+	}
+	if t.ExcludeJobs != "" {
+		if tg.exclude, err = regexp.Compile(t.ExcludeJobs); err != nil {
+			return nil, err
+		}
+	}
+	return tg, nil
+}
+
+// exports tells whether the job of the given full name passes the filters
+func (t *target) exports(jobName string) bool {
+	if t.include != nil && !t.include.MatchString(jobName) {
+		return false
+	}
+	return t.exclude == nil || !t.exclude.MatchString(jobName)
+}
+
+// rebaseURL moves a job URL given by jenkins under the target URL. Jenkins
+// builds them from its own root URL, which may not be the one the exporter
+// reaches it through, e.g. behind a reverse proxy.
+func (t *target) rebaseURL(jobURL string) string {
+	if strings.HasPrefix(jobURL, t.url) {
+		return jobURL
+	}
+	u, err := neturl.Parse(jobURL)
+	if err != nil {
+		return jobURL
+	}
+	i := strings.Index(u.Path, "/job/")
+	if i < 0 {
+		return jobURL
+	}
+	return t.url + u.Path[i+1:]
+}
+
+// closeIdleConnections closes the connections kept alive by the target's
+// client, once it is replaced
+func (t *target) closeIdleConnections() {
+	if transport, ok := t.client.Transport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,220 @@
+package exporter
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/goodbins/go-jenkins-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Jenkins test case struct
+type testCase struct {
+	ClassName string  `json:"className"`
+	Name      string  `json:"name"`
+	Duration  float64 `json:"duration"`
+	Status    string  `json:"status"`
+}
+
+// Jenkins test suite struct
+type testSuite struct {
+	Name     string     `json:"name"`
+	Duration float64    `json:"duration"`
+	Cases    []testCase `json:"cases"`
+}
+
+// Jenkins test report struct. Aggregated reports, from matrix or maven
+// builds, hold their suites in child reports.
+type testReport struct {
+	Suites       []testSuite `json:"suites"`
+	ChildReports []struct {
+		Result struct {
+			Suites []testSuite `json:"suites"`
+		} `json:"result"`
+	} `json:"childReports"`
+}
+
+// allSuites returns the suites of the report and of its child reports
+func (r *testReport) allSuites() []testSuite {
+	suites := r.Suites
+	for _, c := range r.ChildReports {
+		suites = append(suites, c.Result.Suites...)
+	}
+	return suites
+}
+
+// caseOutcome maps a test case status to passed, failed or skipped
+func caseOutcome(status string) string {
+	switch status {
+	case "FAILED", "REGRESSION":
+		return "failed"
+	case "SKIPPED":
+		return "skipped"
+	}
+	return "passed"
+}
+
+// cachedReport is the test report of a job's last completed build, nil when
+// the build has none. Completed builds don't change anymore, so their
+// report is only requested once.
+type cachedReport struct {
+	jobName string
+	number  int
+	report  *testReport
+}
+
+const testSuiteTree = "name,duration,cases[className,name,duration,status]"
+
+// GetTestReport returns the test report of the last completed build of a job
+func GetTestReport(t *target, j *job) (*testReport, error) {
+	var report testReport
+	url := j.URL + "lastCompletedBuild/testReport/api/json?tree=suites[" + testSuiteTree + "],childReports[result[suites[" + testSuiteTree + "]]]"
+	if err := getJSON(t, url, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+var testSuiteCasesDesc = newDesc(
+	"jenkins_test_suite_cases",
+	"Number of test cases of the suite in the last completed build of the job, by outcome",
+	[]string{
+		"jobname",
+		"suite",
+		"status",
+	},
+)
+
+var testSuiteDurationDesc = newDesc(
+	"jenkins_test_suite_duration_seconds",
+	"Duration of the test suite in the last completed build of the job",
+	[]string{
+		"jobname",
+		"suite",
+	},
+)
+
+var testCaseDurationDesc = newDesc(
+	"jenkins_test_case_duration_seconds",
+	"Duration of the slowest test cases in the last completed build of the job",
+	[]string{
+		"jobname",
+		"suite",
+		"case",
+	},
+)
+
+var testCaseFailedDesc = newDesc(
+	"jenkins_test_case_failed",
+	"Failing test cases in the last completed build of the job, always 1",
+	[]string{
+		"jobname",
+		"suite",
+		"case",
+	},
+)
+
+// refreshTestReports returns the test reports of the last completed build
+// of the jobs, keyed by job URL. Only the builds with a test result action
+// have one, and only the ones whose last completed build changed are
+// requested, the others are taken from prev.
+func refreshTestReports(t *target, jobs []job, prev map[string]cachedReport) map[string]cachedReport {
+	var mu sync.Mutex
+	var stale []*job
+	reports := make(map[string]cachedReport)
+	for i := range jobs {
+		j := &jobs[i]
+		// Not to request a report jenkins does not have
+		if j.LastCompletedBuild == nil || j.LastCompletedBuild.Actions.Tests == nil {
+			continue
+		}
+		cached, ok := prev[j.URL]
+		if ok && cached.number == j.LastCompletedBuild.Number {
+			reports[j.URL] = cached
+			continue
+		}
+		stale = append(stale, j)
+	}
+	fetchPerJob(stale, func(j *job) {
+		report, err := GetTestReport(t, j)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err == nil:
+			reports[j.URL] = cachedReport{jobName: j.FullName, number: j.LastCompletedBuild.Number, report: report}
+		case isErrorKind(err, ErrNotFound):
+			// The build did not publish test results
+			reports[j.URL] = cachedReport{jobName: j.FullName, number: j.LastCompletedBuild.Number}
+		default:
+			logrus.Warn("Could not get the test report of ", j.FullName, ": ", err)
+			if cached, ok := prev[j.URL]; ok {
+				reports[j.URL] = cached
+			}
+		}
+	})
+	return reports
+}
+
+// suiteCounts is the number of test cases of a suite by outcome
+type suiteCounts map[string]int
+
+func collectTestReports(ch chan<- prometheus.Metric, snap *snapshot) {
+	for _, cached := range snap.testReports {
+		if cached.report == nil {
+			continue
+		}
+		// Suites of child reports may share a name, add them up
+		durations := make(map[string]float64)
+		counts := make(map[string]suiteCounts)
+		var cases []suiteCase
+		for _, s := range cached.report.allSuites() {
+			durations[s.Name] += s.Duration
+			if counts[s.Name] == nil {
+				counts[s.Name] = suiteCounts{"passed": 0, "failed": 0, "skipped": 0}
+			}
+			for _, c := range s.Cases {
+				counts[s.Name][caseOutcome(c.Status)]++
+				cases = append(cases, suiteCase{suite: s.Name, testCase: c})
+			}
+		}
+		for suite, d := range durations {
+			ch <- prometheus.MustNewConstMetric(testSuiteDurationDesc, prometheus.GaugeValue, d, cached.jobName, suite)
+			for outcome, n := range counts[suite] {
+				ch <- prometheus.MustNewConstMetric(testSuiteCasesDesc, prometheus.GaugeValue, i2F64(n), cached.jobName, suite, outcome)
+			}
+		}
+		if config.Get().TestCasesTop > 0 {
+			collectTopTestCases(ch, cached.jobName, cases)
+		}
+	}
+}
+
+// suiteCase is a test case along with the name of its suite
+type suiteCase struct {
+	suite string
+	testCase
+}
+
+// collectTopTestCases exports the slowest and the failing test cases of a
+// job, up to the configured number of each
+func collectTopTestCases(ch chan<- prometheus.Metric, jobName string, cases []suiteCase) {
+	top := config.Get().TestCasesTop
+	sort.SliceStable(cases, func(i, k int) bool {
+		return cases[i].Duration > cases[k].Duration
+	})
+	// A case may be reported twice, by matrix builds for instance
+	slowest := make(map[[2]string]bool)
+	failing := make(map[[2]string]bool)
+	for _, c := range cases {
+		key := [2]string{c.suite, c.Name}
+		if len(slowest) < top && !slowest[key] {
+			slowest[key] = true
+			ch <- prometheus.MustNewConstMetric(testCaseDurationDesc, prometheus.GaugeValue, c.Duration, jobName, c.suite, c.Name)
+		}
+		if len(failing) < top && !failing[key] && caseOutcome(c.Status) == "failed" {
+			failing[key] = true
+			ch <- prometheus.MustNewConstMetric(testCaseFailedDesc, prometheus.GaugeValue, 1, jobName, c.suite, c.Name)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package exporter
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRefreshTestReportsSkipsBuildsWithoutTests(t *testing.T) {
+	defer useConfig(testConfig())()
+	var requests int32
+	tg, stop := fakeJenkins(t, func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if !strings.HasPrefix(req.URL.Path, "/job/tested/") {
+			t.Errorf("test report of %s requested", req.URL.Path)
+		}
+		rw.Write([]byte(`{"suites":[]}`))
+	})
+	defer stop()
+
+	jobs := []job{
+		{FullName: "tested", URL: tg.url + "job/tested/", LastCompletedBuild: &jStatus{Number: 3, Actions: buildActions{Tests: &testCounts{TotalCount: 2}}}},
+		{FullName: "untested", URL: tg.url + "job/untested/", LastCompletedBuild: &jStatus{Number: 7}},
+		{FullName: "never-built", URL: tg.url + "job/never-built/"},
+	}
+	reports := refreshTestReports(tg, jobs, nil)
+	if _, ok := reports[jobs[0].URL]; !ok || len(reports) != 1 {
+		t.Errorf("reports = %v, want the one of tested", reports)
+	}
+	if requests := atomic.LoadInt32(&requests); requests != 1 {
+		t.Errorf("%d test report requests, want 1", requests)
+	}
+}
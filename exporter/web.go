@@ -0,0 +1,280 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// webConfig is the web config file protecting the endpoints of the
+// exporter, in the format of the prometheus exporter toolkit
+type webConfig struct {
+	TLSServerConfig     *webTLSConfig     `yaml:"tls_server_config"`
+	BasicAuthUsers      map[string]string `yaml:"basic_auth_users"` // bcrypt hashed passwords, by user name
+	BearerTokens        []string          `yaml:"bearer_tokens"`
+	UnauthenticatedPing bool              `yaml:"unauthenticated_ping"` // Lets probes reach /ping, /-/healthy and /-/ready
+}
+
+type webTLSConfig struct {
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	ClientAuthType string `yaml:"client_auth_type"`
+	ClientCAFile   string `yaml:"client_ca_file"`
+	MinVersion     string `yaml:"min_version"`
+}
+
+// Paths let through without credentials with unauthenticated_ping
+var probePaths = map[string]bool{"/ping": true, "/-/healthy": true, "/-/ready": true}
+
+// Client certificate policies, by exporter toolkit name
+var webClientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// TLS versions, by exporter toolkit name
+var webTLSVersions = map[string]uint16{
+	"":      tls.VersionTLS12,
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// webSecurity applies the web config file to the server. The file, and
+// the certificates it points to, are read again once they change, so that
+// renewed certificates and new users are picked up without a restart.
+type webSecurity struct {
+	file *watchedFile
+	tls  bool // Whether the server was started with TLS, fixed until a restart
+
+	mu      sync.Mutex
+	files   map[string]*watchedFile // TLS files, by path
+	content string                  // Web config file content cfg was parsed from
+	cfg     webConfig
+	source  string // Content of every file state was built from
+	state   *webState
+	lastErr string // Last error logged, not to log it on every request
+}
+
+// webState is what a version of the web config files resolves to
+type webState struct {
+	cfg webConfig
+	tls *tls.Config // nil without TLS
+
+	mu         sync.Mutex
+	authorized map[[sha256.Size]byte]bool // Basic auth credentials already checked, bcrypt being slow on purpose
+}
+
+// newWebSecurity loads the web config file, which must be valid at startup
+func newWebSecurity(path string) (*webSecurity, error) {
+	w := &webSecurity{file: &watchedFile{path: path}, files: make(map[string]*watchedFile)}
+	state, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	w.state, w.tls = state, state.tls != nil
+	return w, nil
+}
+
+// current returns the state of the web config files, or the last valid one
+// if they were changed into an invalid one
+func (w *webSecurity) current() *webState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state, err := w.load()
+	if err == nil && (state.tls != nil) != w.tls {
+		err = fmt.Errorf("TLS can't be turned on or off without a restart")
+	}
+	if err != nil {
+		if err.Error() != w.lastErr {
+			logrus.Error("Invalid web config, keeping the current one: ", err)
+		}
+		w.lastErr = err.Error()
+		return w.state
+	}
+	w.lastErr = ""
+	w.state = state
+	return state
+}
+
+// load returns the state of the web config files, built again only if one
+// of them changed
+func (w *webSecurity) load() (*webState, error) {
+	content, err := w.file.read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the web config file: %v", err)
+	}
+	if content != w.content {
+		var cfg webConfig
+		if err := yaml.UnmarshalStrict([]byte(content), &cfg); err != nil {
+			return nil, fmt.Errorf("could not parse the web config file %s: %v", w.file.path, err)
+		}
+		w.content, w.cfg = content, cfg
+	}
+
+	source := content
+	tlsFiles := make(map[string][]byte)
+	if tc := w.cfg.TLSServerConfig; tc != nil {
+		for _, path := range []string{tc.CertFile, tc.KeyFile, tc.ClientCAFile} {
+			if path == "" {
+				continue
+			}
+			f, ok := w.files[path]
+			if !ok {
+				f = &watchedFile{path: path}
+				w.files[path] = f
+			}
+			data, err := f.read()
+			if err != nil {
+				return nil, err
+			}
+			source += "\x00" + path + "\x00" + data
+			tlsFiles[path] = []byte(data)
+		}
+	}
+	if w.state != nil && source == w.source {
+		return w.state, nil
+	}
+	state, err := newWebState(w.cfg, tlsFiles)
+	if err != nil {
+		return nil, err
+	}
+	w.source = source
+	return state, nil
+}
+
+// newWebState checks a web config and builds its TLS config from the
+// content of the files it points to
+func newWebState(cfg webConfig, files map[string][]byte) (*webState, error) {
+	for user, hash := range cfg.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("the password of user %s is not a bcrypt hash: %v", user, err)
+		}
+	}
+	for _, token := range cfg.BearerTokens {
+		if token == "" {
+			return nil, fmt.Errorf("bearer tokens can't be empty")
+		}
+	}
+	state := &webState{cfg: cfg, authorized: make(map[[sha256.Size]byte]bool)}
+	tc := cfg.TLSServerConfig
+	if tc == nil {
+		return state, nil
+	}
+
+	if tc.CertFile == "" || tc.KeyFile == "" {
+		return nil, fmt.Errorf("cert_file and key_file are both required for TLS")
+	}
+	cert, err := tls.X509KeyPair(files[tc.CertFile], files[tc.KeyFile])
+	if err != nil {
+		return nil, fmt.Errorf("could not load the server certificate: %v", err)
+	}
+	minVersion, ok := webTLSVersions[tc.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unknown min_version %s", tc.MinVersion)
+	}
+	clientAuth, ok := webClientAuthTypes[tc.ClientAuthType]
+	if !ok {
+		return nil, fmt.Errorf("unknown client_auth_type %s", tc.ClientAuthType)
+	}
+	state.tls = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+		ClientAuth:   clientAuth,
+	}
+	if tc.ClientCAFile != "" {
+		state.tls.ClientCAs = x509.NewCertPool()
+		if !state.tls.ClientCAs.AppendCertsFromPEM(files[tc.ClientCAFile]) {
+			return nil, fmt.Errorf("no certificate found in the client CA bundle %s", tc.ClientCAFile)
+		}
+	}
+	if clientAuth >= tls.VerifyClientCertIfGiven && state.tls.ClientCAs == nil {
+		return nil, fmt.Errorf("client_ca_file is required to verify the client certificates")
+	}
+	return state, nil
+}
+
+// tlsConfig hands each TLS handshake the current certificate and client
+// certificate policy
+func (w *webSecurity) tlsConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return w.current().tls, nil
+		},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &w.current().tls.Certificates[0], nil
+		},
+	}
+}
+
+// handler lets the requests through to next once authenticated, if the web
+// config asks for it
+func (w *webSecurity) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if w.current().allows(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		logrus.Debug("Unauthorized request to ", req.URL.Path, " from ", req.RemoteAddr)
+		rw.Header().Set("WWW-Authenticate", `Basic realm="go-jenkins-exporter"`)
+		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+// allows tells whether a request carries a bearer token or basic auth
+// credentials of the web config, when it has any
+func (s *webState) allows(req *http.Request) bool {
+	if len(s.cfg.BasicAuthUsers) == 0 && len(s.cfg.BearerTokens) == 0 {
+		return true
+	}
+	if s.cfg.UnauthenticatedPing && probePaths[req.URL.Path] {
+		return true
+	}
+
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token := []byte(strings.TrimPrefix(auth, "Bearer "))
+		for _, t := range s.cfg.BearerTokens {
+			if subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+				return true
+			}
+		}
+		return false
+	}
+
+	user, password, ok := req.BasicAuth()
+	if !ok {
+		return false
+	}
+	hash, ok := s.cfg.BasicAuthUsers[user]
+	if !ok {
+		return false
+	}
+	key := sha256.Sum256([]byte(user + "\x00" + hash + "\x00" + password))
+	s.mu.Lock()
+	known := s.authorized[key]
+	s.mu.Unlock()
+	if known {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+	s.mu.Lock()
+	s.authorized[key] = true
+	s.mu.Unlock()
+	return true
+}
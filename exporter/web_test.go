@@ -0,0 +1,64 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestWebStateAllows(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secured := webConfig{
+		BasicAuthUsers: map[string]string{"prometheus": string(hash)},
+		BearerTokens:   []string{"token"},
+	}
+	ping := secured
+	ping.UnauthenticatedPing = true
+
+	tests := []struct {
+		name   string
+		cfg    webConfig
+		path   string
+		auth   []string // Authorization headers, besides basic auth
+		basic  []string // User and password
+		allows bool
+	}{
+		{name: "no users nor tokens", path: "/metrics", allows: true},
+		{name: "no credentials", cfg: secured, path: "/metrics"},
+		{name: "bearer token", cfg: secured, path: "/metrics", auth: []string{"Bearer token"}, allows: true},
+		{name: "wrong bearer token", cfg: secured, path: "/metrics", auth: []string{"Bearer wrong"}},
+		{name: "basic auth", cfg: secured, path: "/metrics", basic: []string{"prometheus", "secret"}, allows: true},
+		{name: "wrong password", cfg: secured, path: "/metrics", basic: []string{"prometheus", "wrong"}},
+		{name: "unknown user", cfg: secured, path: "/metrics", basic: []string{"grafana", "secret"}},
+		{name: "wrong bearer token with valid basic auth", cfg: secured, path: "/metrics",
+			auth: []string{"Bearer wrong"}, basic: []string{"prometheus", "secret"}},
+		{name: "ping without unauthenticated_ping", cfg: secured, path: "/ping"},
+		{name: "ping", cfg: ping, path: "/ping", allows: true},
+		{name: "healthy", cfg: ping, path: "/-/healthy", allows: true},
+		{name: "ready", cfg: ping, path: "/-/ready", allows: true},
+		{name: "metrics with unauthenticated_ping", cfg: ping, path: "/metrics"},
+		{name: "probe with unauthenticated_ping", cfg: ping, path: "/probe"},
+	}
+	for _, tt := range tests {
+		state, err := newWebState(tt.cfg, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		req := httptest.NewRequest("GET", "http://localhost:5000"+tt.path, nil)
+		if tt.basic != nil {
+			req.SetBasicAuth(tt.basic[0], tt.basic[1])
+		}
+		// Bearer tokens come first, as sent along with basic auth
+		req.Header["Authorization"] = append(tt.auth, req.Header["Authorization"]...)
+		// Twice, the second time going through the cache of checked credentials
+		for i := 0; i < 2; i++ {
+			if got := state.allows(req); got != tt.allows {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.allows)
+			}
+		}
+	}
+}